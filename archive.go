@@ -0,0 +1,349 @@
+// Copyright 2015 Alex Browne.  All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package forms
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"os"
+	"path"
+	"strings"
+)
+
+// errStopWalk can be returned from an archiveWalkFunc to stop walking
+// an archive early without reporting an error.
+var errStopWalk = errors.New("forms: stop walking archive")
+
+// errUnknownArchive is returned by walkArchive when a file is neither a
+// zip nor a tar.gz archive.
+var errUnknownArchive = errors.New("forms: file is not a zip or tar.gz archive")
+
+// errArchiveTooLarge is returned by walkArchive when the gzip stream of a
+// tar.gz archive decompresses to more than the given limit.
+var errArchiveTooLarge = errors.New("forms: archive is too large to inspect")
+
+// MaxTarGzStreamSize is the maximum number of bytes which will be
+// decompressed from a tar.gz archive by the archive validators. Entries in
+// a tar archive are stored one after another, so even validators which
+// only look at the names of entries, such as ArchiveMaxEntries, must
+// decompress everything before the last entry. If an archive decompresses
+// to more than MaxTarGzStreamSize bytes, the validators add an error
+// saying it is too large, so a small tar.gz bomb cannot use unbounded CPU.
+const MaxTarGzStreamSize = 256 << 20
+
+// archiveEntry describes a single entry in a zip or tar.gz archive.
+type archiveEntry struct {
+	// name is the name of the entry exactly as stored in the archive.
+	name  string
+	isDir bool
+	// isLink is true for symbolic and hard links, and linkname is the
+	// path the link points to.
+	isLink   bool
+	linkname string
+	// open returns a reader for the uncompressed contents of the entry.
+	open func() (io.ReadCloser, error)
+}
+
+type archiveWalkFunc func(entry *archiveEntry) error
+
+// walkArchive calls fn for each entry in the zip or tar.gz archive
+// identified by header, in the order they are stored. Nothing is
+// extracted to disk, and the contents of an entry are only decompressed
+// if fn calls entry.open. If the gzip stream of a tar.gz archive
+// decompresses to more than streamLimit bytes, it returns
+// errArchiveTooLarge.
+func walkArchive(header *multipart.FileHeader, streamLimit int64, fn archiveWalkFunc) error {
+	head, err := peekFile(header, 4)
+	if err != nil {
+		return err
+	}
+	file, err := header.Open()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	switch {
+	case bytes.HasPrefix(head, []byte("PK")):
+		err = walkZip(file, header.Size, fn)
+	case bytes.HasPrefix(head, []byte("\x1f\x8b")):
+		err = walkTarGz(file, streamLimit, fn)
+	default:
+		err = errUnknownArchive
+	}
+	if err == errStopWalk {
+		return nil
+	}
+	return err
+}
+
+func walkZip(file multipart.File, size int64, fn archiveWalkFunc) error {
+	reader, err := zip.NewReader(file, size)
+	if err != nil {
+		return err
+	}
+	for _, f := range reader.File {
+		entry := &archiveEntry{
+			name:  f.Name,
+			isDir: f.FileInfo().IsDir(),
+			open:  f.Open,
+		}
+		if f.Mode()&os.ModeSymlink != 0 {
+			// the target of a symbolic link is stored as its contents
+			target, err := readZipLinkname(f)
+			if err != nil {
+				return err
+			}
+			entry.isLink, entry.linkname = true, target
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func walkTarGz(file multipart.File, streamLimit int64, fn archiveWalkFunc) error {
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer gzipReader.Close()
+	tarReader := tar.NewReader(&inflateLimitReader{r: gzipReader, remaining: streamLimit + 1})
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		entry := &archiveEntry{
+			name:     header.Name,
+			isDir:    header.Typeflag == tar.TypeDir,
+			isLink:   header.Typeflag == tar.TypeSymlink || header.Typeflag == tar.TypeLink,
+			linkname: header.Linkname,
+			open: func() (io.ReadCloser, error) {
+				return ioutil.NopCloser(tarReader), nil
+			},
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+}
+
+// inflateLimitReader reads from r until more than remaining - 1 bytes have
+// been read, after which it returns errArchiveTooLarge.
+type inflateLimitReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *inflateLimitReader) Read(p []byte) (int, error) {
+	if l.remaining <= 0 {
+		return 0, errArchiveTooLarge
+	}
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	return n, err
+}
+
+// maxZipLinknameLen is the maximum length of the target of a symbolic link
+// in a zip archive which readZipLinkname will read.
+const maxZipLinknameLen = 4096
+
+// readZipLinkname returns the target of the symbolic link f.
+func readZipLinkname(f *zip.File) (string, error) {
+	r, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	target, err := ioutil.ReadAll(io.LimitReader(r, maxZipLinknameLen+1))
+	if err != nil {
+		return "", err
+	}
+	if len(target) > maxZipLinknameLen {
+		return "", errors.New("forms: zip symbolic link target is too long")
+	}
+	return string(target), nil
+}
+
+// isUnsafeArchivePath returns true iff name would point outside of the
+// directory an archive is extracted to, e.g. "../../etc/passwd" or
+// "/etc/passwd".
+func isUnsafeArchivePath(name string) bool {
+	// Treat backslashes as separators too, since archives created on
+	// windows may use them.
+	name = strings.Replace(name, "\\", "/", -1)
+	if name == "" || strings.HasPrefix(name, "/") || strings.Contains(name, "\x00") {
+		return true
+	}
+	if len(name) >= 2 && name[1] == ':' {
+		// windows volume name, e.g. "C:/Windows"
+		return true
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// walkArchiveField walks the archive identified by field. If the archive
+// could not be read, it adds an error to the Validator and returns the
+// corresponding ValidationResult.
+func (v *Validator) walkArchiveField(field string, streamLimit int64, fn archiveWalkFunc) *ValidationResult {
+	if err := walkArchive(v.data.GetFile(field), streamLimit, fn); errors.Is(err, errArchiveTooLarge) {
+		msg := fmt.Sprintf("%s is too large to inspect.", field)
+		return v.AddError(field, msg)
+	} else if err != nil {
+		msg := fmt.Sprintf("%s must be a valid zip or tar.gz archive.", field)
+		return v.AddError(field, msg)
+	}
+	return nil
+}
+
+// ArchiveMaxEntries will add an error to the Validator if the zip or tar.gz
+// archive identified by field contains more than max entries (including
+// directories), or if it is not a valid archive. If the file does not
+// exist, it does not add an error to the Validator.
+func (v *Validator) ArchiveMaxEntries(field string, max int) *ValidationResult {
+	if !v.data.FileExists(field) {
+		return validationOk
+	}
+	count := 0
+	if result := v.walkArchiveField(field, MaxTarGzStreamSize, func(entry *archiveEntry) error {
+		count++
+		if count > max {
+			return errStopWalk
+		}
+		return nil
+	}); result != nil {
+		return result
+	}
+	if count > max {
+		msg := fmt.Sprintf("%s cannot contain more than %d files.", field, max)
+		return v.AddError(field, msg)
+	}
+	return validationOk
+}
+
+// ArchiveMaxSize will add an error to the Validator if the total uncompressed
+// size of the zip or tar.gz archive identified by field is more than max
+// bytes, or if it is not a valid archive. Sizes declared in the archive are
+// not trusted; entries are decompressed (in memory, without being stored)
+// and decompression stops as soon as the limit is exceeded, so the check is
+// safe to use against zip bombs. For tar.gz archives, MaxTarGzStreamSize
+// also applies. If the file does not exist, it does not add an error to the
+// Validator.
+func (v *Validator) ArchiveMaxSize(field string, max int64) *ValidationResult {
+	if !v.data.FileExists(field) {
+		return validationOk
+	}
+	total := int64(0)
+	if result := v.walkArchiveField(field, MaxTarGzStreamSize, func(entry *archiveEntry) error {
+		if entry.isDir {
+			return nil
+		}
+		r, err := entry.open()
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		n, err := io.CopyN(ioutil.Discard, r, max-total+1)
+		total += n
+		if total > max {
+			return errStopWalk
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+		return nil
+	}); result != nil {
+		return result
+	}
+	if total > max {
+		msg := fmt.Sprintf("%s cannot contain more than %s of uncompressed data.", field, formatFileSize(max))
+		return v.AddError(field, msg)
+	}
+	return validationOk
+}
+
+// ArchiveAcceptExts will add an error to the Validator if any file in the zip
+// or tar.gz archive identified by field has an extension which is not in exts,
+// or if it is not a valid archive. exts should be one or more allowed file
+// extensions, not including the preceding ".", and are compared without
+// regard to case. Directories are ignored. If the file does not exist, it
+// does not add an error to the Validator.
+func (v *Validator) ArchiveAcceptExts(field string, exts ...string) *ValidationResult {
+	if !v.data.FileExists(field) {
+		return validationOk
+	}
+	badName := ""
+	if result := v.walkArchiveField(field, MaxTarGzStreamSize, func(entry *archiveEntry) error {
+		if entry.isDir {
+			return nil
+		}
+		gotExt := strings.TrimPrefix(path.Ext(entry.name), ".")
+		for _, ext := range exts {
+			if strings.EqualFold(ext, gotExt) {
+				return nil
+			}
+		}
+		badName = entry.name
+		return errStopWalk
+	}); result != nil {
+		return result
+	}
+	if badName != "" {
+		msg := fmt.Sprintf("%s contains the file %s, which has an extension that is not allowed. Allowed extensions include: %s", field, badName, humanList(exts, "and"))
+		return v.AddError(field, msg)
+	}
+	return validationOk
+}
+
+// ArchiveSafePaths will add an error to the Validator if any entry in the zip
+// or tar.gz archive identified by field has an absolute path or a path which
+// would point outside of the directory it is extracted to (e.g.
+// "../../etc/passwd"), or if it is not a valid archive. Symbolic and hard
+// links are checked in the same way against the path they point to, so
+// that a link with a safe name cannot be used by a later entry to write
+// outside of the directory. If the file does not exist, it does not add an
+// error to the Validator.
+func (v *Validator) ArchiveSafePaths(field string) *ValidationResult {
+	if !v.data.FileExists(field) {
+		return validationOk
+	}
+	badName := ""
+	if result := v.walkArchiveField(field, MaxTarGzStreamSize, func(entry *archiveEntry) error {
+		if isUnsafeArchivePath(entry.name) {
+			badName = entry.name
+			return errStopWalk
+		}
+		if entry.isLink && isUnsafeArchivePath(entry.linkname) {
+			badName = entry.name + " -> " + entry.linkname
+			return errStopWalk
+		}
+		return nil
+	}); result != nil {
+		return result
+	}
+	if badName != "" {
+		msg := fmt.Sprintf("%s contains a file with an unsafe path: %s", field, badName)
+		return v.AddError(field, msg)
+	}
+	return validationOk
+}
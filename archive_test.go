@@ -0,0 +1,301 @@
+// Copyright 2015 Alex Browne.  All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package forms
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"strings"
+	"testing"
+)
+
+type testArchiveEntry struct {
+	name    string
+	content string
+}
+
+func createTestZip(t *testing.T, entries []testArchiveEntry) []byte {
+	buf := bytes.NewBuffer([]byte{})
+	zipWriter := zip.NewWriter(buf)
+	for _, entry := range entries {
+		w, err := zipWriter.Create(entry.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(entry.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func createTestTarGz(t *testing.T, entries []testArchiveEntry) []byte {
+	buf := bytes.NewBuffer([]byte{})
+	gzipWriter := gzip.NewWriter(buf)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for _, entry := range entries {
+		header := &tar.Header{
+			Name:     entry.name,
+			Mode:     0644,
+			Size:     int64(len(entry.content)),
+			Typeflag: tar.TypeReg,
+		}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tarWriter.Write([]byte(entry.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func createTestArchiveData(t *testing.T, entries []testArchiveEntry) *Data {
+	data := newData()
+	zipHeader, err := createTestFileHeader("archive.zip", createTestZip(t, entries))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data.AddFile("zip", zipHeader)
+	tarGzHeader, err := createTestFileHeader("archive.tar.gz", createTestTarGz(t, entries))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data.AddFile("tarGz", tarGzHeader)
+	notArchive, err := createTestFileHeader("archive.zip", []byte("Hello!"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data.AddFile("notArchive", notArchive)
+	return data
+}
+
+func TestArchiveMaxEntries(t *testing.T) {
+	data := createTestArchiveData(t, []testArchiveEntry{
+		{"a.csv", "1,2,3"},
+		{"b.csv", "4,5,6"},
+		{"c.csv", "7,8,9"},
+	})
+	for _, key := range []string{"zip", "tarGz"} {
+		val := data.Validator()
+		val.ArchiveMaxEntries(key, 3)
+		if val.HasErrors() {
+			t.Errorf("Expected no errors for %s but got errors: %v", key, val.Messages())
+		}
+		val.ArchiveMaxEntries(key, 2)
+		if len(val.Messages()) != 1 {
+			t.Errorf("Expected 1 validation error for %s but got %d.", key, len(val.Messages()))
+		}
+	}
+
+	val := data.Validator()
+	val.ArchiveMaxEntries("notArchive", 10)
+	if len(val.Messages()) != 1 {
+		t.Fatalf("Expected 1 validation error but got %d.", len(val.Messages()))
+	}
+	if msg := val.Messages()[0]; !strings.Contains(msg, "valid zip or tar.gz archive") {
+		t.Errorf("Expected message to say the archive was invalid but got: %s", msg)
+	}
+}
+
+func TestArchiveMaxSize(t *testing.T) {
+	data := createTestArchiveData(t, []testArchiveEntry{
+		{"a.csv", strings.Repeat("a", 1000)},
+		{"b.csv", strings.Repeat("b", 1000)},
+		// highly compressible, like a zip bomb
+		{"c.csv", strings.Repeat("c", 100000)},
+	})
+	for _, key := range []string{"zip", "tarGz"} {
+		val := data.Validator()
+		val.ArchiveMaxSize(key, 102000)
+		if val.HasErrors() {
+			t.Errorf("Expected no errors for %s but got errors: %v", key, val.Messages())
+		}
+		val.ArchiveMaxSize(key, 101999)
+		if len(val.Messages()) != 1 {
+			t.Errorf("Expected 1 validation error for %s but got %d.", key, len(val.Messages()))
+		}
+	}
+}
+
+func TestMaxTarGzStreamSize(t *testing.T) {
+	data := createTestArchiveData(t, []testArchiveEntry{
+		{"a.csv", "1,2,3"},
+		// highly compressible, like a tar.gz bomb
+		{"b.csv", strings.Repeat("b", 100000)},
+		{"c.csv", "7,8,9"},
+	})
+	val := data.Validator()
+	noop := func(entry *archiveEntry) error { return nil }
+	val.walkArchiveField("tarGz", 10000, noop)
+	if len(val.Messages()) != 1 {
+		t.Fatalf("Expected 1 validation error but got %d.", len(val.Messages()))
+	}
+	if msg := val.Messages()[0]; msg != "tarGz is too large to inspect." {
+		t.Errorf(`Expected message to be "tarGz is too large to inspect." but got "%s"`, msg)
+	}
+	if err := walkArchive(data.GetFile("tarGz"), 200000, noop); err != nil {
+		t.Errorf("Expected no error with a larger limit but got %v.", err)
+	}
+	// zip entries are only decompressed when they are read
+	if err := walkArchive(data.GetFile("zip"), 10000, noop); err != nil {
+		t.Errorf("Expected no error for zip but got %v.", err)
+	}
+}
+
+func TestArchiveAcceptExts(t *testing.T) {
+	data := createTestArchiveData(t, []testArchiveEntry{
+		{"data/a.csv", "1,2,3"},
+		{"data/b.CSV", "4,5,6"},
+		{"data/run.exe", "MZ"},
+	})
+	for _, key := range []string{"zip", "tarGz"} {
+		val := data.Validator()
+		val.ArchiveAcceptExts(key, "csv", "exe")
+		if val.HasErrors() {
+			t.Errorf("Expected no errors for %s but got errors: %v", key, val.Messages())
+		}
+		val.ArchiveAcceptExts(key, "csv", "tsv")
+		if len(val.Messages()) != 1 {
+			t.Errorf("Expected 1 validation error for %s but got %d.", key, len(val.Messages()))
+			continue
+		}
+		expected := "contains the file data/run.exe, which has an extension that is not allowed. Allowed extensions include: csv and tsv"
+		if msg := val.Messages()[0]; !strings.Contains(msg, expected) {
+			t.Errorf(`Expected message to contain "%s" but got "%s"`, expected, msg)
+		}
+	}
+}
+
+func TestArchiveSafePaths(t *testing.T) {
+	dotDot := createTestArchiveData(t, []testArchiveEntry{
+		{"a.csv", "1,2,3"},
+		{"data/../b.csv", "4,5,6"},
+	})
+	// data/../b.csv stays inside the archive root, but it is simpler
+	// and safer to reject any ".." component.
+	unsafe := createTestArchiveData(t, []testArchiveEntry{
+		{"a.csv", "1,2,3"},
+		{"../../etc/passwd", "root"},
+	})
+	for _, key := range []string{"zip", "tarGz"} {
+		val := unsafe.Validator()
+		val.ArchiveSafePaths(key)
+		if len(val.Messages()) != 1 {
+			t.Errorf("Expected 1 validation error for %s but got %d.", key, len(val.Messages()))
+		}
+		val = dotDot.Validator()
+		val.ArchiveSafePaths(key)
+		if len(val.Messages()) != 1 {
+			t.Errorf("Expected 1 validation error for %s but got %d.", key, len(val.Messages()))
+		}
+	}
+
+	table := []struct {
+		name     string
+		expected bool
+	}{
+		{"a.csv", false},
+		{"dir/a.csv", false},
+		{"dir/", false},
+		{"..foo/a.csv", false},
+		{"../a.csv", true},
+		{"dir/../../a.csv", true},
+		{"/etc/passwd", true},
+		{"..\\..\\windows\\system32", true},
+		{"C:\\Windows", true},
+		{"", true},
+	}
+	for _, test := range table {
+		if got := isUnsafeArchivePath(test.name); got != test.expected {
+			t.Errorf("isUnsafeArchivePath(%q) was incorrect. Expected %t, but got %t.", test.name, test.expected, got)
+		}
+	}
+}
+
+func TestArchiveSafePathsLinks(t *testing.T) {
+	table := []struct {
+		typeflag byte
+		linkname string
+		expected bool
+	}{
+		{tar.TypeSymlink, "data/a.csv", false},
+		{tar.TypeSymlink, "../../etc", true},
+		{tar.TypeSymlink, "/etc", true},
+		{tar.TypeLink, "data/a.csv", false},
+		{tar.TypeLink, "../../etc/passwd", true},
+	}
+	for i, test := range table {
+		buf := bytes.NewBuffer([]byte{})
+		gzipWriter := gzip.NewWriter(buf)
+		tarWriter := tar.NewWriter(gzipWriter)
+		if err := tarWriter.WriteHeader(&tar.Header{
+			Name:     "link",
+			Mode:     0777,
+			Typeflag: test.typeflag,
+			Linkname: test.linkname,
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if err := tarWriter.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := gzipWriter.Close(); err != nil {
+			t.Fatal(err)
+		}
+		tarGzHeader, err := createTestFileHeader("archive.tar.gz", buf.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := newData()
+		data.AddFile("tarGz", tarGzHeader)
+		val := data.Validator()
+		val.ArchiveSafePaths("tarGz")
+		if got := val.HasErrors(); got != test.expected {
+			t.Errorf("Expected HasErrors to be %t for case %d but got %t: %v", test.expected, i, got, val.Messages())
+		}
+	}
+
+	buf := bytes.NewBuffer([]byte{})
+	zipWriter := zip.NewWriter(buf)
+	header := &zip.FileHeader{Name: "link"}
+	header.SetMode(os.ModeSymlink | 0777)
+	w, err := zipWriter.CreateHeader(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("../../etc")); err != nil {
+		t.Fatal(err)
+	}
+	if err := zipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zipHeader, err := createTestFileHeader("archive.zip", buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := newData()
+	data.AddFile("zip", zipHeader)
+	val := data.Validator()
+	val.ArchiveSafePaths("zip")
+	if len(val.Messages()) != 1 {
+		t.Fatalf("Expected 1 validation error but got %d.", len(val.Messages()))
+	}
+	if msg := val.Messages()[0]; !strings.Contains(msg, "link -> ../../etc") {
+		t.Errorf("Expected message to contain the link target but got: %s", msg)
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2015 Alex Browne.  All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package forms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// LookupFunc reports whether value exists in some external store, such as
+// a database table. It is used by AsyncGroup.Unique and AsyncGroup.Exists.
+// An error means the lookup could not be done, not that value is invalid.
+type LookupFunc func(ctx context.Context, value string) (bool, error)
+
+// AsyncGroup holds validation checks which need I/O, such as database
+// lookups, so that they can be run concurrently by Run. Create one with
+// Validator.Async. An AsyncGroup should only be run once.
+type AsyncGroup struct {
+	v      *Validator
+	limit  int
+	checks []asyncCheck
+}
+
+// asyncCheck is a check added to an AsyncGroup. run returns invalid if the
+// value is invalid, or err if the check could not be done.
+type asyncCheck struct {
+	field string
+	run   func(ctx context.Context, value string, data *Data) (invalid error, err error)
+}
+
+// Async returns a new AsyncGroup whose checks add their errors to the
+// Validator. At most limit checks are run at the same time. If limit is 0
+// or less, there is no limit. For example:
+//
+//	group := val.Async(4)
+//	group.Unique("username", usernameExists)
+//	group.Exists("category_id", categoryExists)
+//	if _, err := group.Run(req.Context()); err != nil {
+//		// the database could not be queried, or the request was canceled
+//	}
+func (v *Validator) Async(limit int) *AsyncGroup {
+	return &AsyncGroup{
+		v:     v,
+		limit: limit,
+	}
+}
+
+// Apply adds a check which applies rule to the first element of
+// data.Values[field] when the group is run, in the same way as
+// Validator.ApplyContext. If rule returns an error caused by the context
+// being canceled or its deadline passing, the error is returned by Run
+// instead of being added to the Validator.
+func (g *AsyncGroup) Apply(field string, rule Rule) {
+	g.checks = append(g.checks, asyncCheck{
+		field: field,
+		run: func(ctx context.Context, value string, data *Data) (error, error) {
+			err := rule.Validate(ctx, value, data)
+			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+				return nil, err
+			}
+			return err, nil
+		},
+	})
+}
+
+// Unique adds a check which, when the group is run, will add an error to
+// the Validator if lookup reports that the first element of
+// data.Values[field] already exists, e.g. because a username is taken. If
+// the value is empty, lookup is not called and no error is added.
+func (g *AsyncGroup) Unique(field string, lookup LookupFunc) {
+	g.addLookup(field, lookup, true, &RuleError{
+		Code:    "unique",
+		Message: FieldPlaceholder + " is already taken.",
+	})
+}
+
+// Exists adds a check which, when the group is run, will add an error to
+// the Validator if lookup reports that the first element of
+// data.Values[field] does not exist, e.g. because there is no category with
+// the given id. If the value is empty, lookup is not called and no error
+// is added.
+func (g *AsyncGroup) Exists(field string, lookup LookupFunc) {
+	g.addLookup(field, lookup, false, &RuleError{
+		Code:    "exists",
+		Message: FieldPlaceholder + " does not exist.",
+	})
+}
+
+// addLookup adds a check which returns invalid if the result of lookup is
+// equal to invalidIfFound.
+func (g *AsyncGroup) addLookup(field string, lookup LookupFunc, invalidIfFound bool, invalid error) {
+	g.checks = append(g.checks, asyncCheck{
+		field: field,
+		run: func(ctx context.Context, value string, data *Data) (error, error) {
+			if strings.TrimSpace(value) == "" {
+				return nil, nil
+			}
+			found, err := lookup(ctx, value)
+			if err != nil {
+				return nil, err
+			}
+			if found == invalidIfFound {
+				return invalid, nil
+			}
+			return nil, nil
+		},
+	})
+}
+
+// asyncOutcome is the outcome of running an asyncCheck.
+type asyncOutcome struct {
+	ran     bool
+	invalid error
+	err     error
+}
+
+// Run runs the checks in the group concurrently, respecting the group's
+// limit, and waits for them to finish. The errors of any invalid values are
+// then added to the Validator in the order the checks were added, so the
+// result does not depend on which checks finish first. Run returns the
+// ValidationResults of the checks which failed, so that their fields or
+// messages can be changed.
+//
+// If ctx is canceled or its deadline passes, checks which have not started
+// are skipped, and Run returns ctx.Err() after the running checks return.
+// Otherwise, if any check could not be done (e.g. a LookupFunc returned an
+// error), Run returns the first such error in the order the checks were
+// added. The errors of other checks are still added to the Validator.
+func (g *AsyncGroup) Run(ctx context.Context) ([]*ValidationResult, error) {
+	outcomes := make([]asyncOutcome, len(g.checks))
+	var sem chan struct{}
+	if g.limit > 0 {
+		sem = make(chan struct{}, g.limit)
+	}
+	wg := sync.WaitGroup{}
+	for i, check := range g.checks {
+		if ctx.Err() != nil {
+			break
+		}
+		if sem != nil {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+			}
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		wg.Add(1)
+		go func(i int, check asyncCheck) {
+			defer wg.Done()
+			if sem != nil {
+				defer func() { <-sem }()
+			}
+			invalid, err := check.run(ctx, g.v.data.Get(check.field), g.v.data)
+			outcomes[i] = asyncOutcome{ran: true, invalid: invalid, err: err}
+		}(i, check)
+	}
+	wg.Wait()
+
+	results := []*ValidationResult{}
+	var firstErr error
+	for i, check := range g.checks {
+		outcome := outcomes[i]
+		switch {
+		case !outcome.ran:
+			continue
+		case outcome.err != nil:
+			if firstErr == nil {
+				firstErr = fmt.Errorf("forms: could not check %s: %w", check.field, outcome.err)
+			}
+		case outcome.invalid != nil:
+			results = append(results, g.v.addRuleError(check.field, outcome.invalid))
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return results, err
+	}
+	return results, firstErr
+}
@@ -0,0 +1,150 @@
+// Copyright 2015 Alex Browne.  All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package forms
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// slowLookup returns a LookupFunc which reports whether a value is in
+// values after sleeping for delay, and records the maximum number of
+// concurrent calls in maxRunning.
+func slowLookup(values map[string]bool, delay time.Duration, mut *sync.Mutex, running *int, maxRunning *int) LookupFunc {
+	return func(ctx context.Context, value string) (bool, error) {
+		mut.Lock()
+		*running++
+		if *running > *maxRunning {
+			*maxRunning = *running
+		}
+		mut.Unlock()
+		defer func() {
+			mut.Lock()
+			*running--
+			mut.Unlock()
+		}()
+		select {
+		case <-time.After(delay):
+			return values[value], nil
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	}
+}
+
+func TestAsyncGroup(t *testing.T) {
+	data := newData()
+	data.Add("username", "bob")
+	data.Add("email", "bob@example.com")
+	data.Add("category_id", "42")
+	data.Add("tag_id", "7")
+	data.Add("nickname", "")
+	mut := &sync.Mutex{}
+	running, maxRunning := 0, 0
+	users := map[string]bool{"bob": true, "bob@example.com": true}
+	categories := map[string]bool{"1": true, "2": true}
+	tags := map[string]bool{"7": true}
+
+	val := data.Validator()
+	val.Require("username")
+	group := val.Async(2)
+	// the checks which finish first are added last, to make sure the
+	// results are merged in declaration order
+	group.Unique("username", slowLookup(users, 40*time.Millisecond, mut, &running, &maxRunning))
+	group.Exists("category_id", slowLookup(categories, 20*time.Millisecond, mut, &running, &maxRunning))
+	group.Unique("email", slowLookup(users, 10*time.Millisecond, mut, &running, &maxRunning))
+	group.Exists("tag_id", slowLookup(tags, time.Millisecond, mut, &running, &maxRunning))
+	group.Unique("nickname", slowLookup(users, time.Millisecond, mut, &running, &maxRunning))
+	group.Apply("username", RuleFunc(func(ctx context.Context, value string, data *Data) error {
+		if value == "bob" {
+			return &RuleError{Code: "reserved", Message: "{field} is reserved."}
+		}
+		return nil
+	}))
+	results, err := group.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 4 {
+		t.Errorf("Expected 4 results but got %d.", len(results))
+	}
+	if maxRunning > 2 {
+		t.Errorf("Expected at most 2 lookups at a time but got %d.", maxRunning)
+	}
+	expectedMessages := []string{
+		"username is already taken.",
+		"category_id does not exist.",
+		"email is already taken.",
+		"username is reserved.",
+	}
+	if !reflect.DeepEqual(val.Messages(), expectedMessages) {
+		t.Errorf("Expected messages %v but got %v.", expectedMessages, val.Messages())
+	}
+	expectedCodes := []string{"unique", "exists", "unique", "reserved"}
+	if !reflect.DeepEqual(val.Codes(), expectedCodes) {
+		t.Errorf("Expected codes %v but got %v.", expectedCodes, val.Codes())
+	}
+}
+
+func TestAsyncGroupErrors(t *testing.T) {
+	data := newData()
+	data.Add("username", "bob")
+	data.Add("email", "bob@example.com")
+	errDatabase := errors.New("database is down")
+	val := data.Validator()
+	group := val.Async(0)
+	group.Unique("username", func(ctx context.Context, value string) (bool, error) {
+		return false, errDatabase
+	})
+	group.Unique("email", func(ctx context.Context, value string) (bool, error) {
+		return true, nil
+	})
+	results, err := group.Run(context.Background())
+	if !errors.Is(err, errDatabase) {
+		t.Errorf("Expected the database error but got %v.", err)
+	}
+	if len(results) != 1 || !reflect.DeepEqual(val.Fields(), []string{"email"}) {
+		t.Errorf("Expected an error for email only but got %v.", val.ErrorMap())
+	}
+}
+
+func TestAsyncGroupCancel(t *testing.T) {
+	data := newData()
+	for _, key := range []string{"a", "b", "c", "d"} {
+		data.Add(key, key)
+	}
+	mut := &sync.Mutex{}
+	running, maxRunning := 0, 0
+	lookup := slowLookup(map[string]bool{}, time.Minute, mut, &running, &maxRunning)
+	val := data.Validator()
+	group := val.Async(1)
+	started := make(chan bool, 4)
+	for _, key := range []string{"a", "b", "c", "d"} {
+		group.Exists(key, func(ctx context.Context, value string) (bool, error) {
+			started <- true
+			return lookup(ctx, value)
+		})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	begin := time.Now()
+	results, err := group.Run(ctx)
+	if err != context.DeadlineExceeded {
+		t.Errorf("Expected context.DeadlineExceeded but got %v.", err)
+	}
+	if elapsed := time.Since(begin); elapsed > 5*time.Second {
+		t.Errorf("Expected Run to return soon after the deadline but it took %s.", elapsed)
+	}
+	if len(results) != 0 || val.HasErrors() {
+		t.Errorf("Expected no validation errors but got %v.", val.Messages())
+	}
+	if len(started) != 1 {
+		t.Errorf("Expected only 1 lookup to start but %d did.", len(started))
+	}
+}
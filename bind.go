@@ -0,0 +1,106 @@
+// Copyright 2015 Alex Browne.  All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package forms
+
+import (
+	"errors"
+	"mime/multipart"
+	"reflect"
+	"strings"
+)
+
+var fileHeaderType = reflect.TypeOf(&multipart.FileHeader{})
+
+// Bind sets the fields of the struct pointed to by v from data. Each
+// exported field is set from the key given by its "form" tag, or from the
+// key with the same name as the field if it has no tag. Fields tagged
+// `form:"-"` are ignored, as are fields whose key does not exist. Fields of
+// embedded structs are bound as if they belonged to v.
+//
+// A field may have any of the types supported by Get, including types with
+// a converter registered by RegisterConverter. Slice fields receive every
+// value for their key, and *multipart.FileHeader fields receive the file
+// for their key. Bool fields are true if any value for their key is true,
+// as with GetBool. If a value cannot be converted, Bind returns a
+// *ConversionError and v may have been partially set.
+func (d Data) Bind(v interface{}) error {
+	ptr := reflect.ValueOf(v)
+	if ptr.Kind() != reflect.Ptr || ptr.IsNil() || ptr.Elem().Kind() != reflect.Struct {
+		return errors.New("forms: Bind requires a non-nil pointer to a struct")
+	}
+	return d.bindStruct(ptr.Elem())
+}
+
+func (d Data) bindStruct(val reflect.Value) error {
+	typ := val.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		key := field.Tag.Get("form")
+		if key == "-" {
+			continue
+		}
+		if field.Anonymous && key == "" && field.Type.Kind() == reflect.Struct && !hasConverter(field.Type) {
+			// the exported fields of an unexported embedded struct are
+			// still promoted, so bind them too
+			if err := d.bindStruct(val.Field(i)); err != nil {
+				return err
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if key == "" {
+			key = field.Name
+		}
+		if err := d.bindField(val.Field(i), key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d Data) bindField(field reflect.Value, key string) error {
+	typ := field.Type()
+	if typ == fileHeaderType {
+		if d.FileExists(key) {
+			field.Set(reflect.ValueOf(d.GetFile(key)))
+		}
+		return nil
+	}
+	if typ.Kind() == reflect.Slice && !hasConverter(typ) {
+		if !d.KeyExists(key) {
+			return nil
+		}
+		elements := d.elements(key)
+		slice := reflect.MakeSlice(typ, 0, len(elements))
+		for _, element := range elements {
+			result, err := convert(element, typ.Elem())
+			if err != nil {
+				return &ConversionError{Key: key, Value: element, Type: typ.Elem(), Err: err}
+			}
+			slice = reflect.Append(slice, result)
+		}
+		field.Set(slice)
+		return nil
+	}
+	if !d.KeyExists(key) || len(d.Values[key]) == 0 {
+		return nil
+	}
+	if typ.Kind() == reflect.Bool && !hasConverter(typ) {
+		result, err := parseBoolValues(d.Values[key])
+		if err != nil {
+			return &ConversionError{Key: key, Value: strings.Join(d.Values[key], ","), Type: typ, Err: err}
+		}
+		field.SetBool(result)
+		return nil
+	}
+	result, err := convert(d.Get(key), typ)
+	if err != nil {
+		return &ConversionError{Key: key, Value: d.Get(key), Type: typ, Err: err}
+	}
+	field.Set(result)
+	return nil
+}
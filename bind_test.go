@@ -0,0 +1,162 @@
+// Copyright 2015 Alex Browne.  All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package forms
+
+import (
+	"errors"
+	"math/big"
+	"mime/multipart"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testMoney struct {
+	Currency string
+	Cents    int64
+}
+
+type testTimestamps struct {
+	Created time.Time `form:"created_at"`
+}
+
+type testOrder struct {
+	testTimestamps
+	ID       uint64                `form:"id"`
+	Country  testCountryCode       `form:"country"`
+	Total    testMoney             `form:"total"`
+	Tags     []string              `form:"tags"`
+	Quantity []int                 `form:"quantity"`
+	Server   net.IP                `form:"server"`
+	Note     *string               `form:"note"`
+	Receipt  *multipart.FileHeader `form:"receipt"`
+	Timeout  time.Duration
+	Ignored  string `form:"-"`
+	secret   string
+}
+
+func init() {
+	RegisterConverter(func(str string) (testMoney, error) {
+		parts := strings.SplitN(str, " ", 2)
+		if len(parts) != 2 {
+			return testMoney{}, errors.New("money must have a currency and an amount")
+		}
+		amount, err := parseDecimal(parts[1])
+		if err != nil {
+			return testMoney{}, err
+		}
+		cents := new(big.Rat).Mul(amount, big.NewRat(100, 1))
+		if !cents.IsInt() {
+			return testMoney{}, errors.New("money cannot have fractions of a cent")
+		}
+		return testMoney{Currency: parts[0], Cents: cents.Num().Int64()}, nil
+	})
+}
+
+func TestBind(t *testing.T) {
+	data := newData()
+	data.Values = map[string][]string{
+		"id":         []string{"18446744073709551615"},
+		"created_at": []string{"2015-03-21"},
+		"country":    []string{"nz"},
+		"total":      []string{"NZD 12"},
+		"tags":       []string{"a", "b"},
+		"quantity":   []string{"1", "2"},
+		"server":     []string{"10.0.0.1"},
+		"note":       []string{"leave at the door"},
+		"Timeout":    []string{"5m"},
+		"Ignored":    []string{"x"},
+		"secret":     []string{"x"},
+	}
+	receipt, err := createTestFileHeader("receipt.txt", []byte("Thanks!"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data.AddFile("receipt", receipt)
+
+	order := testOrder{Ignored: "unchanged"}
+	if err := data.Bind(&order); err != nil {
+		t.Fatal(err)
+	}
+	note := "leave at the door"
+	expected := testOrder{
+		testTimestamps: testTimestamps{Created: time.Date(2015, 3, 21, 0, 0, 0, 0, time.UTC)},
+		ID:             18446744073709551615,
+		Country:        "NZ",
+		Total:          testMoney{Currency: "NZD", Cents: 1200},
+		Tags:           []string{"a", "b"},
+		Quantity:       []int{1, 2},
+		Server:         net.ParseIP("10.0.0.1"),
+		Note:           &note,
+		Receipt:        receipt,
+		Timeout:        5 * time.Minute,
+		Ignored:        "unchanged",
+	}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("Expected %+v but got %+v.", expected, order)
+	}
+
+	data.Set("quantity", "lots")
+	err = data.Bind(&order)
+	convErr := &ConversionError{}
+	if !errors.As(err, &convErr) || convErr.Key != "quantity" || convErr.Value != "lots" {
+		t.Errorf("Expected a *ConversionError for quantity but got %v.", err)
+	}
+	if err := data.Bind(order); err == nil {
+		t.Error("Expected an error when binding to a non-pointer but got none.")
+	}
+}
+
+func TestTypeOf(t *testing.T) {
+	data := newData()
+	data.Add("country", "nz")
+	data.Add("server", "10.0.0.1")
+	data.Add("total", "NZD 12.50")
+	data.Add("id", "1")
+	data.Add("word", "one")
+	val := data.Validator()
+	val.TypeOf("country", testCountryCode(""))
+	val.TypeOf("server", net.IP{})
+	val.TypeOf("total", testMoney{})
+	val.TypeOf("id", uint(0))
+	val.TypeOf("missing", 0)
+	if val.HasErrors() {
+		t.Errorf("Expected no errors but got errors: %v", val.Messages())
+	}
+
+	val.TypeOf("word", testCountryCode(""))
+	val.TypeOf("word", testColor(0))
+	val.TypeOf("word", testMoney{})
+	val.TypeOf("word", uint(0))
+	val.TypeOf("word", time.Time{})
+	expected := []string{
+		"word must be a test country code",
+		"word must be a test color",
+		"word must be a test money",
+		"word must be a non-negative integer",
+		"word must be a date",
+	}
+	if !reflect.DeepEqual(val.Messages(), expected) {
+		t.Errorf("Expected messages %v but got %v.", expected, val.Messages())
+	}
+}
+
+func TestSplitCamelCase(t *testing.T) {
+	table := map[string]string{
+		"CountryCode": "country code",
+		"UUID":        "UUID",
+		"UUIDValue":   "UUID value",
+		"userID":      "user ID",
+		"Money":       "money",
+		"x":           "x",
+	}
+	for name, expected := range table {
+		if got := splitCamelCase(name); got != expected {
+			t.Errorf("splitCamelCase(%q) was incorrect. Expected %q, but got %q.", name, expected, got)
+		}
+	}
+}
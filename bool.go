@@ -0,0 +1,88 @@
+// Copyright 2015 Alex Browne.  All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package forms
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TrueValues and FalseValues are the values which GetBool, LookupBool,
+// TypeBool, Accepted, Get and Bind accept as true and false respectively.
+// They are compared case-insensitively, ignoring surrounding white space.
+// "on" is included because it is what browsers submit for a checked
+// checkbox without a value attribute.
+var (
+	TrueValues  = []string{"1", "t", "true", "y", "yes", "on"}
+	FalseValues = []string{"0", "f", "false", "n", "no", "off"}
+)
+
+// parseBool converts str to a bool using TrueValues and FalseValues.
+func parseBool(str string) (bool, error) {
+	str = strings.TrimSpace(str)
+	for _, val := range TrueValues {
+		if strings.EqualFold(str, val) {
+			return true, nil
+		}
+	}
+	for _, val := range FalseValues {
+		if strings.EqualFold(str, val) {
+			return false, nil
+		}
+	}
+	return false, fmt.Errorf("forms: invalid boolean %q", str)
+}
+
+// parseBoolValues converts vals to a single bool, which is true if any of
+// vals is true. This supports the common pattern of preceding a checkbox
+// with a hidden field of the same name:
+//
+//	<input type="hidden" name="subscribe" value="no">
+//	<input type="checkbox" name="subscribe" value="yes">
+//
+// When the checkbox is unchecked, only the hidden field's value is
+// submitted. When it is checked, both values are submitted. An error is
+// returned if vals is empty or any of vals is invalid.
+func parseBoolValues(vals []string) (bool, error) {
+	if len(vals) == 0 {
+		return false, fmt.Errorf("forms: invalid boolean %q", "")
+	}
+	result := false
+	for _, val := range vals {
+		b, err := parseBool(val)
+		if err != nil {
+			return false, err
+		}
+		result = result || b
+	}
+	return result, nil
+}
+
+// LookupBool is like GetBool but also reports whether the key exists, so
+// that an unchecked checkbox (for which browsers submit nothing) can be
+// distinguished from a value which is explicitly false.
+func (d Data) LookupBool(key string) (value bool, ok bool) {
+	if !d.KeyExists(key) || len(d.Values[key]) == 0 {
+		return false, false
+	}
+	if result, err := parseBoolValues(d.Values[key]); err != nil {
+		panic(err)
+	} else {
+		return result, true
+	}
+}
+
+// Accepted will add an error to the Validator unless the value of field is
+// one of TrueValues. It is intended for checkboxes such as "I agree to the
+// terms of service", which are not submitted at all when unchecked. If
+// there is more than one value for field, any of them may be true, as with
+// GetBool.
+func (v *Validator) Accepted(field string) *ValidationResult {
+	if result, err := parseBoolValues(v.data.Values[field]); err != nil || !result {
+		msg := fmt.Sprintf("%s must be accepted.", field)
+		return v.AddError(field, msg)
+	}
+	return validationOk
+}
@@ -0,0 +1,100 @@
+// Copyright 2015 Alex Browne.  All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package forms
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseBool(t *testing.T) {
+	for _, str := range []string{"1", "true", "TRUE", "Yes", "y", "on", " On "} {
+		if got, err := parseBool(str); err != nil || !got {
+			t.Errorf("parseBool(%q) was incorrect. Expected true, but got %t (%v).", str, got, err)
+		}
+	}
+	for _, str := range []string{"0", "false", "No", "N", "off", "OFF"} {
+		if got, err := parseBool(str); err != nil || got {
+			t.Errorf("parseBool(%q) was incorrect. Expected false, but got %t (%v).", str, got, err)
+		}
+	}
+	for _, str := range []string{"", "maybe", "2"} {
+		if _, err := parseBool(str); err == nil {
+			t.Errorf("Expected an error for %q but got none.", str)
+		}
+	}
+}
+
+func TestLookupBool(t *testing.T) {
+	data := newData()
+	data.Values = map[string][]string{
+		"checked":   []string{"on"},
+		"hidden":    []string{"no"},
+		"both":      []string{"no", "yes"},
+		"unchecked": []string{"off"},
+	}
+	table := []struct {
+		key           string
+		expected      bool
+		expectedFound bool
+	}{
+		{"checked", true, true},
+		{"hidden", false, true},
+		{"both", true, true},
+		{"unchecked", false, true},
+		{"missing", false, false},
+	}
+	for _, test := range table {
+		got, found := data.LookupBool(test.key)
+		if got != test.expected || found != test.expectedFound {
+			t.Errorf("%s was incorrect. Expected %t, %t, but got %t, %t.", test.key, test.expected, test.expectedFound, got, found)
+		}
+		if data.GetBool(test.key) != test.expected {
+			t.Errorf("GetBool(%q) was incorrect. Expected %t.", test.key, test.expected)
+		}
+	}
+
+	var form struct {
+		Subscribe bool `form:"both"`
+	}
+	if err := data.Bind(&form); err != nil || !form.Subscribe {
+		t.Errorf("Expected Bind to set Subscribe to true but got %t (%v).", form.Subscribe, err)
+	}
+
+	data.Add("invalid", "maybe")
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected LookupBool to panic for an invalid boolean but it did not.")
+		}
+	}()
+	data.LookupBool("invalid")
+}
+
+func TestAccepted(t *testing.T) {
+	data := newData()
+	data.Add("terms", "on")
+	data.Add("privacy", "0")
+	data.Add("privacy", "1")
+	data.Add("newsletter", "off")
+	data.Add("invalid", "maybe")
+	val := data.Validator()
+	val.Accepted("terms")
+	val.Accepted("privacy")
+	if val.HasErrors() {
+		t.Errorf("Expected no errors but got errors: %v", val.Messages())
+	}
+
+	val.Accepted("newsletter")
+	val.Accepted("invalid")
+	val.Accepted("missing")
+	expected := []string{
+		"newsletter must be accepted.",
+		"invalid must be accepted.",
+		"missing must be accepted.",
+	}
+	if !reflect.DeepEqual(val.Messages(), expected) {
+		t.Errorf("Expected messages %v but got %v.", expected, val.Messages())
+	}
+}
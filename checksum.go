@@ -0,0 +1,198 @@
+// Copyright 2015 Alex Browne.  All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package forms
+
+import (
+	"bytes"
+	"crypto"
+	// Register the hash functions which may be used for checksums.
+	_ "crypto/md5"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"mime/multipart"
+	"strings"
+)
+
+// digestAlgorithms maps the algorithm names used in the Digest and
+// Content-Digest headers to hash functions.
+var digestAlgorithms = map[string]crypto.Hash{
+	"md5":     crypto.MD5,
+	"sha":     crypto.SHA1,
+	"sha-1":   crypto.SHA1,
+	"sha-256": crypto.SHA256,
+	"sha-512": crypto.SHA512,
+}
+
+// newChecksumCollector returns a partFunc which computes a checksum of the
+// first file part for each key using each of hashes, and stores the results
+// in data.
+func newChecksumCollector(data *Data, hashes []crypto.Hash) partFunc {
+	data.checksums = map[string]map[crypto.Hash][]byte{}
+	return func(part *multipart.Part) error {
+		key := part.FormName()
+		if part.FileName() == "" || key == "" {
+			return nil
+		}
+		if _, found := data.checksums[key]; found {
+			// Data only holds the first file for each key.
+			return nil
+		}
+		hashers := make([]hash.Hash, len(hashes))
+		writers := make([]io.Writer, len(hashes))
+		for i, h := range hashes {
+			hashers[i] = h.New()
+			writers[i] = hashers[i]
+		}
+		if _, err := io.Copy(io.MultiWriter(writers...), part); err != nil {
+			return err
+		}
+		sums := map[crypto.Hash][]byte{}
+		for i, h := range hashes {
+			sums[h] = hashers[i].Sum(nil)
+		}
+		data.checksums[key] = sums
+		return nil
+	}
+}
+
+// FileChecksum returns the checksum of the contents of the file associated
+// with key, computed with the given hash function (e.g. crypto.SHA256). If
+// the checksum was already computed while parsing the request (see
+// ParseOptions.Hashes), the file is not read again. If there is no file
+// associated with key, it returns nil (not an error).
+func (d Data) FileChecksum(key string, h crypto.Hash) ([]byte, error) {
+	header, found := d.Files[key]
+	if !found {
+		return nil, nil
+	}
+	if sum, found := d.checksums[key][h]; found {
+		return sum, nil
+	}
+	if !h.Available() {
+		return nil, fmt.Errorf("forms: hash function %v is not available", h)
+	}
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	hasher := h.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return nil, err
+	}
+	return hasher.Sum(nil), nil
+}
+
+// decodeChecksum decodes a checksum which may be encoded as either hex
+// or base64. It returns nil if the checksum could not be decoded or is
+// the wrong size for h.
+func decodeChecksum(encoded string, h crypto.Hash) []byte {
+	encoded = strings.TrimSpace(encoded)
+	if len(encoded) == h.Size()*2 {
+		if sum, err := hex.DecodeString(encoded); err == nil {
+			return sum
+		}
+	}
+	encodings := []*base64.Encoding{
+		base64.StdEncoding,
+		base64.RawStdEncoding,
+		base64.URLEncoding,
+		base64.RawURLEncoding,
+	}
+	for _, encoding := range encodings {
+		if sum, err := encoding.DecodeString(encoded); err == nil && len(sum) == h.Size() {
+			return sum
+		}
+	}
+	return nil
+}
+
+// parseDigestHeaders returns the checksums declared in the given part
+// headers, keyed by hash function. It understands Content-MD5 (RFC 1864),
+// Digest (RFC 3230) and Content-Digest (RFC 9530). Any algorithms which
+// are not supported are ignored.
+func parseDigestHeaders(header *multipart.FileHeader) map[crypto.Hash]string {
+	digests := map[crypto.Hash]string{}
+	if md5 := header.Header.Get("Content-MD5"); md5 != "" {
+		digests[crypto.MD5] = md5
+	}
+	for _, name := range []string{"Digest", "Content-Digest"} {
+		for _, value := range header.Header[name] {
+			for _, item := range strings.Split(value, ",") {
+				i := strings.Index(item, "=")
+				if i == -1 {
+					continue
+				}
+				algorithm := strings.ToLower(strings.TrimSpace(item[:i]))
+				if h, found := digestAlgorithms[algorithm]; found {
+					// Content-Digest wraps the value in colons.
+					digests[h] = strings.Trim(strings.TrimSpace(item[i+1:]), ":")
+				}
+			}
+		}
+	}
+	return digests
+}
+
+// VerifyChecksum will add an error to the Validator if the checksum of the
+// file identified by field, computed with h, does not match the first value
+// of data.Values[checksumField]. The checksum may be encoded as hex or
+// base64. If either the file or the checksum does not exist, it does not
+// add an error to the Validator.
+func (v *Validator) VerifyChecksum(field string, h crypto.Hash, checksumField string) *ValidationResult {
+	if !v.data.FileExists(field) || strings.TrimSpace(v.data.Get(checksumField)) == "" {
+		return validationOk
+	}
+	return v.verifyChecksum(field, h, v.data.Get(checksumField))
+}
+
+// VerifyDigestHeader will add an error to the Validator if the checksum of
+// the file identified by field does not match any of the checksums declared
+// in the headers of the file's part in the multipart form, i.e. the
+// Content-MD5, Digest, or Content-Digest headers. Supported algorithms are
+// MD5, SHA-1, SHA-256 and SHA-512. If the file does not exist or no
+// supported checksums were declared, it does not add an error to the
+// Validator.
+func (v *Validator) VerifyDigestHeader(field string) *ValidationResult {
+	if !v.data.FileExists(field) {
+		return validationOk
+	}
+	digests := parseDigestHeaders(v.data.GetFile(field))
+	// check in a consistent order, strongest hash first
+	for _, h := range []crypto.Hash{crypto.SHA512, crypto.SHA256, crypto.SHA1, crypto.MD5} {
+		if encoded, found := digests[h]; found {
+			if result := v.verifyChecksum(field, h, encoded); result != validationOk {
+				return result
+			}
+		}
+	}
+	return validationOk
+}
+
+func (v *Validator) verifyChecksum(field string, h crypto.Hash, encoded string) *ValidationResult {
+	expected := decodeChecksum(encoded, h)
+	if expected == nil {
+		return v.addChecksumError(field)
+	}
+	got, err := v.data.FileChecksum(field, h)
+	if err != nil {
+		return v.AddError(field, "Could not read file.")
+	}
+	if !bytes.Equal(got, expected) {
+		return v.addChecksumError(field)
+	}
+	return validationOk
+}
+
+func (v *Validator) addChecksumError(field string) *ValidationResult {
+	msg := fmt.Sprintf("%s does not match the provided checksum.", field)
+	return v.AddError(field, msg)
+}
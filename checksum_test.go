@@ -0,0 +1,158 @@
+// Copyright 2015 Alex Browne.  All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package forms
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+type testFilePart struct {
+	key      string
+	filename string
+	content  []byte
+	headers  map[string]string
+}
+
+// createTestMultipartRequest returns a multipart request with the given
+// fields and file parts.
+func createTestMultipartRequest(t *testing.T, fields map[string]string, files []testFilePart) *http.Request {
+	body := bytes.NewBuffer([]byte{})
+	form := multipart.NewWriter(body)
+	for key, value := range fields {
+		if err := form.WriteField(key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range files {
+		partHeader := textproto.MIMEHeader{}
+		partHeader.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, file.key, file.filename))
+		partHeader.Set("Content-Type", "application/octet-stream")
+		for name, value := range file.headers {
+			partHeader.Set(name, value)
+		}
+		w, err := form.CreatePart(partHeader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(file.content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := form.Close(); err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest("POST", "/", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Add("Content-Type", "multipart/form-data; boundary="+form.Boundary())
+	return req
+}
+
+func TestParseWithHashes(t *testing.T) {
+	small := []byte("Hello!")
+	large := []byte(strings.Repeat("0123456789", 10000))
+	smallMD5 := md5.Sum(small)
+	largeSHA256 := sha256.Sum256(large)
+	req := createTestMultipartRequest(t, map[string]string{
+		"name":           "Bob",
+		"smallChecksum":  hex.EncodeToString(smallMD5[:]),
+		"largeChecksum":  base64.StdEncoding.EncodeToString(largeSHA256[:]),
+		"wrongChecksum":  strings.Repeat("0", 32),
+		"brokenChecksum": "not a checksum",
+	}, []testFilePart{
+		{key: "small", filename: "small.txt", content: small},
+		{key: "large", filename: "large.txt", content: large},
+	})
+
+	data, err := ParseWithOptions(req, &ParseOptions{
+		MaxMemory: 1024,
+		Hashes:    []crypto.Hash{crypto.MD5, crypto.SHA256},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.Get("name") != "Bob" {
+		t.Errorf(`Expected name to be "Bob" but got %q.`, data.Get("name"))
+	}
+	if data.checksums["large"] == nil || data.checksums["small"] == nil {
+		t.Fatalf("Expected checksums to be computed while parsing but got %v.", data.checksums)
+	}
+	if got, err := data.FileChecksum("large", crypto.SHA256); err != nil {
+		t.Error(err)
+	} else if !bytes.Equal(got, largeSHA256[:]) {
+		t.Errorf("Expected SHA-256 checksum %x but got %x.", largeSHA256, got)
+	}
+	// not computed while parsing
+	sha1Sum, err := data.FileChecksum("small", crypto.SHA1)
+	if err != nil {
+		t.Error(err)
+	} else if len(sha1Sum) != crypto.SHA1.Size() {
+		t.Errorf("Expected a SHA-1 checksum but got %x.", sha1Sum)
+	}
+
+	val := data.Validator()
+	val.VerifyChecksum("small", crypto.MD5, "smallChecksum")
+	val.VerifyChecksum("large", crypto.SHA256, "largeChecksum")
+	val.VerifyChecksum("missing", crypto.MD5, "smallChecksum")
+	val.VerifyChecksum("small", crypto.MD5, "missingChecksum")
+	if val.HasErrors() {
+		t.Errorf("Expected no errors but got errors: %v", val.Messages())
+	}
+	val.VerifyChecksum("large", crypto.MD5, "wrongChecksum")
+	val.VerifyChecksum("large", crypto.MD5, "brokenChecksum")
+	if len(val.Messages()) != 2 {
+		t.Errorf("Expected 2 validation errors but got %d.", len(val.Messages()))
+	}
+}
+
+func TestVerifyDigestHeader(t *testing.T) {
+	content := []byte("Hello!")
+	md5Sum := md5.Sum(content)
+	sha256Sum := sha256.Sum256(content)
+	md5Base64 := base64.StdEncoding.EncodeToString(md5Sum[:])
+	sha256Base64 := base64.StdEncoding.EncodeToString(sha256Sum[:])
+	req := createTestMultipartRequest(t, nil, []testFilePart{
+		{key: "contentMD5", filename: "a.txt", content: content, headers: map[string]string{
+			"Content-MD5": md5Base64,
+		}},
+		{key: "digest", filename: "b.txt", content: content, headers: map[string]string{
+			"Digest": "SHA-256=" + sha256Base64 + ",unixsum=30637",
+		}},
+		{key: "contentDigest", filename: "c.txt", content: content, headers: map[string]string{
+			"Content-Digest": "sha-256=:" + sha256Base64 + ":",
+		}},
+		{key: "none", filename: "d.txt", content: content},
+		{key: "wrong", filename: "e.txt", content: []byte("Goodbye!"), headers: map[string]string{
+			"Content-MD5": md5Base64,
+		}},
+	})
+	data, err := Parse(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	val := data.Validator()
+	for _, key := range []string{"contentMD5", "digest", "contentDigest", "none", "missing"} {
+		val.VerifyDigestHeader(key)
+	}
+	if val.HasErrors() {
+		t.Errorf("Expected no errors but got errors: %v", val.Messages())
+	}
+	val.VerifyDigestHeader("wrong")
+	if len(val.Messages()) != 1 {
+		t.Errorf("Expected 1 validation error but got %d.", len(val.Messages()))
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright 2015 Alex Browne.  All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package forms
+
+import (
+	"fmt"
+	"time"
+)
+
+// Operator is a comparison operator used by Validator.Compare.
+type Operator string
+
+const (
+	OpEqual          Operator = "=="
+	OpNotEqual       Operator = "!="
+	OpGreater        Operator = ">"
+	OpGreaterOrEqual Operator = ">="
+	OpLess           Operator = "<"
+	OpLessOrEqual    Operator = "<="
+)
+
+// ErrorTarget controls which field or fields the error added by
+// Validator.Compare is associated with.
+type ErrorTarget int
+
+const (
+	// TargetFirst associates the error with the first field.
+	TargetFirst ErrorTarget = iota
+	// TargetSecond associates the error with the second field.
+	TargetSecond
+	// TargetBoth adds an error for each field, e.g. so that both inputs of
+	// a range can be highlighted.
+	TargetBoth
+)
+
+// NotEqual will add an error to the Validator if the first element of
+// data.Values[field1] is equal to the first element of data.Values[field2],
+// e.g. NotEqual("new_password", "old_password"). The values are compared
+// as strings and the error is associated with field1.
+func (v *Validator) NotEqual(field1 string, field2 string) *ValidationResult {
+	if v.data.Get(field1) == v.data.Get(field2) {
+		return v.addCompareError(field1, field2, OpNotEqual, false, TargetFirst)
+	}
+	return validationOk
+}
+
+// GreaterThanField will add an error to the Validator if the first element
+// of data.Values[field1] is not greater than the first element of
+// data.Values[field2], e.g. GreaterThanField("max_price", "min_price"). It
+// is equivalent to Compare(field1, OpGreater, field2, TargetFirst), so
+// numbers and dates are both supported.
+func (v *Validator) GreaterThanField(field1 string, field2 string) *ValidationResult {
+	return v.Compare(field1, OpGreater, field2, TargetFirst)
+}
+
+// LessThanField will add an error to the Validator if the first element of
+// data.Values[field1] is not less than the first element of
+// data.Values[field2]. It is equivalent to Compare(field1, OpLess, field2,
+// TargetFirst), so numbers and dates are both supported.
+func (v *Validator) LessThanField(field1 string, field2 string) *ValidationResult {
+	return v.Compare(field1, OpLess, field2, TargetFirst)
+}
+
+// Compare will add an error to the Validator unless the first element of
+// data.Values[field1] and the first element of data.Values[field2] satisfy
+// "field1 op field2". If both values are numbers, they are compared exactly
+// as numbers. Otherwise, if both values are dates in one of DateLayouts,
+// they are compared as times. Otherwise, OpEqual and OpNotEqual compare the
+// values as strings, and the other operators add an error saying the value
+// must be a number or a date.
+//
+// For operators other than OpEqual and OpNotEqual, if either value is
+// empty, Compare does not add an error to the Validator, so that optional
+// fields can be compared. Use Require to make them required.
+//
+// target controls which field the error is associated with. If target is
+// TargetBoth, an error is added for each field and the result for field1 is
+// returned. Compare panics if op is not one of the Op constants.
+func (v *Validator) Compare(field1 string, op Operator, field2 string, target ErrorTarget) *ValidationResult {
+	val1, val2 := v.data.Get(field1), v.data.Get(field2)
+	ordered := op != OpEqual && op != OpNotEqual
+	if ordered && (v.isBlank(field1) || v.isBlank(field2)) {
+		return validationOk
+	}
+	cmp, dates, ok := compareValues(val1, val2)
+	if !ok {
+		if ordered {
+			bad := field1
+			if _, _, ok := compareValues(val1, val1); ok {
+				bad = field2
+			}
+			return v.addTypeError(bad, "number or date")
+		}
+		if val1 < val2 {
+			cmp = -1
+		} else if val1 > val2 {
+			cmp = 1
+		}
+	}
+	var satisfied bool
+	switch op {
+	case OpEqual:
+		satisfied = cmp == 0
+	case OpNotEqual:
+		satisfied = cmp != 0
+	case OpGreater:
+		satisfied = cmp > 0
+	case OpGreaterOrEqual:
+		satisfied = cmp >= 0
+	case OpLess:
+		satisfied = cmp < 0
+	case OpLessOrEqual:
+		satisfied = cmp <= 0
+	default:
+		panic(fmt.Sprintf("forms: unknown comparison operator %q", op))
+	}
+	if satisfied {
+		return validationOk
+	}
+	return v.addCompareError(field1, field2, op, dates, target)
+}
+
+// compareValues compares val1 and val2 as numbers if they are both numbers
+// or as times if they are both dates. It returns -1, 0 or 1 as val1 is less
+// than, equal to or greater than val2. ok is false if the values are
+// neither both numbers nor both dates.
+func compareValues(val1 string, val2 string) (cmp int, dates bool, ok bool) {
+	if num1, err := parseDecimal(val1); err == nil {
+		if num2, err := parseDecimal(val2); err == nil {
+			return num1.Cmp(num2), false, true
+		}
+		return 0, false, false
+	}
+	if t1, err := parseTime(val1, time.UTC, nil); err == nil {
+		if t2, err := parseTime(val2, time.UTC, nil); err == nil {
+			return t1.Compare(t2), true, true
+		}
+	}
+	return 0, false, false
+}
+
+// compareExplanations holds the explanations used in error messages for each
+// operator, for numbers and for dates.
+var compareExplanations = map[Operator][2]string{
+	OpNotEqual:       {"different from", "different from"},
+	OpGreater:        {"greater than", "after"},
+	OpGreaterOrEqual: {"greater than or equal to", "on or after"},
+	OpLess:           {"less than", "before"},
+	OpLessOrEqual:    {"less than or equal to", "on or before"},
+}
+
+func (v *Validator) addCompareError(field1 string, field2 string, op Operator, dates bool, target ErrorTarget) *ValidationResult {
+	msg := ""
+	if op == OpEqual {
+		msg = fmt.Sprintf("%s and %s must match.", field1, field2)
+	} else {
+		explanation := compareExplanations[op][0]
+		if dates {
+			explanation = compareExplanations[op][1]
+		}
+		msg = fmt.Sprintf("%s must be %s %s.", field1, explanation, field2)
+	}
+	switch target {
+	case TargetSecond:
+		return v.AddError(field2, msg)
+	case TargetBoth:
+		result := v.AddError(field1, msg)
+		v.AddError(field2, msg)
+		return result
+	default:
+		return v.AddError(field1, msg)
+	}
+}
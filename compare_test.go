@@ -0,0 +1,108 @@
+// Copyright 2015 Alex Browne.  All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package forms
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNotEqual(t *testing.T) {
+	data := newData()
+	data.Add("old_password", "hunter2")
+	data.Add("new_password", "correct horse")
+	data.Add("repeated_password", "hunter2")
+	val := data.Validator()
+	val.NotEqual("new_password", "old_password")
+	if val.HasErrors() {
+		t.Errorf("Expected no errors but got errors: %v", val.Messages())
+	}
+
+	val.NotEqual("repeated_password", "old_password")
+	expected := map[string][]string{
+		"repeated_password": {"repeated_password must be different from old_password."},
+	}
+	if !reflect.DeepEqual(val.ErrorMap(), expected) {
+		t.Errorf("Expected errors %v but got %v.", expected, val.ErrorMap())
+	}
+}
+
+func TestFieldComparisons(t *testing.T) {
+	data := newData()
+	data.Add("min_price", "9.99")
+	data.Add("max_price", "10")
+	data.Add("big", "9007199254740993")
+	data.Add("bigger", "9007199254740992.5")
+	data.Add("start", "2015-03-21")
+	data.Add("end", "2015-03-21T12:00:00Z")
+	data.Add("word", "ten")
+	data.Add("hex", "0x10")
+	data.Add("empty", "")
+
+	val := data.Validator()
+	val.GreaterThanField("max_price", "min_price")
+	val.LessThanField("min_price", "max_price")
+	val.LessThanField("bigger", "big")
+	val.LessThanField("start", "end")
+	val.GreaterThanField("max_price", "empty")
+	val.Compare("max_price", OpEqual, "max_price", TargetFirst)
+	val.Compare("max_price", OpGreaterOrEqual, "max_price", TargetFirst)
+	val.Compare("min_price", OpNotEqual, "max_price", TargetFirst)
+	if val.HasErrors() {
+		t.Errorf("Expected no errors but got errors: %v", val.Messages())
+	}
+
+	val.GreaterThanField("min_price", "max_price")
+	val.GreaterThanField("bigger", "big")
+	val.Compare("start", OpGreaterOrEqual, "end", TargetFirst)
+	val.Compare("end", OpLessOrEqual, "start", TargetFirst)
+	val.LessThanField("word", "max_price")
+	val.LessThanField("max_price", "word")
+	val.LessThanField("max_price", "start")
+	val.GreaterThanField("hex", "max_price")
+	expected := []string{
+		"min_price must be greater than max_price.",
+		"bigger must be greater than big.",
+		"start must be on or after end.",
+		"end must be on or before start.",
+		"word must be a number or date",
+		"word must be a number or date",
+		"start must be a number or date",
+		"hex must be a number or date",
+	}
+	if !reflect.DeepEqual(val.Messages(), expected) {
+		t.Errorf("Expected messages %v but got %v.", expected, val.Messages())
+	}
+}
+
+func TestCompareTarget(t *testing.T) {
+	data := newData()
+	data.Add("min", "5")
+	data.Add("max", "1")
+	data.Add("password", "a")
+	data.Add("confirm", "b")
+	val := data.Validator()
+	val.Compare("min", OpLessOrEqual, "max", TargetBoth)
+	val.Compare("password", OpEqual, "confirm", TargetSecond)
+	expectedFields := []string{"min", "max", "confirm"}
+	if !reflect.DeepEqual(val.Fields(), expectedFields) {
+		t.Errorf("Expected fields %v but got %v.", expectedFields, val.Fields())
+	}
+	expectedMessages := []string{
+		"min must be less than or equal to max.",
+		"min must be less than or equal to max.",
+		"password and confirm must match.",
+	}
+	if !reflect.DeepEqual(val.Messages(), expectedMessages) {
+		t.Errorf("Expected messages %v but got %v.", expectedMessages, val.Messages())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected Compare to panic for an unknown operator but it did not.")
+		}
+	}()
+	val.Compare("min", Operator("<>"), "max", TargetFirst)
+}
@@ -0,0 +1,293 @@
+// Copyright 2015 Alex Browne.  All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package forms
+
+import (
+	"encoding"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// ConversionError is returned when a value cannot be converted to the
+// requested type.
+type ConversionError struct {
+	// Key is the key of the value which could not be converted.
+	Key string
+	// Value is the value which could not be converted.
+	Value string
+	// Type is the type the value was being converted to.
+	Type reflect.Type
+	// Err is the underlying error, if any.
+	Err error
+}
+
+func (e *ConversionError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("forms: cannot convert %s=%q to %s", e.Key, e.Value, e.Type)
+	}
+	return fmt.Sprintf("forms: cannot convert %s=%q to %s: %s", e.Key, e.Value, e.Type, e.Err)
+}
+
+func (e *ConversionError) Unwrap() error {
+	return e.Err
+}
+
+// converterFunc converts a string to a value of some type, which is
+// returned as an interface{}.
+type converterFunc func(str string) (interface{}, error)
+
+var (
+	convertersMu sync.RWMutex
+	converters   = map[reflect.Type]converterFunc{
+		reflect.TypeOf(time.Duration(0)): func(str string) (interface{}, error) {
+			return time.ParseDuration(str)
+		},
+		reflect.TypeOf(time.Time{}): func(str string) (interface{}, error) {
+			return parseTime(str, time.UTC, nil)
+		},
+		reflect.TypeOf(&big.Rat{}): func(str string) (interface{}, error) {
+			return parseDecimal(str)
+		},
+	}
+)
+
+// RegisterConverter registers fn as the function used to convert strings to
+// values of type T by Get, GetAll, GetOr, Bind and TypeOf. It replaces any
+// converter previously registered for T, and takes precedence over the built
+// in conversions, so it can be used to change how e.g. time.Time values are
+// parsed. It is safe to call RegisterConverter concurrently, but it is
+// usually called from an init function.
+func RegisterConverter[T any](fn func(str string) (T, error)) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	convertersMu.Lock()
+	defer convertersMu.Unlock()
+	converters[typ] = func(str string) (interface{}, error) {
+		return fn(str)
+	}
+}
+
+// hasConverter returns true iff values of type typ can be converted by a
+// registered converter or an encoding.TextUnmarshaler implementation, as
+// opposed to a conversion based on the kind of typ.
+func hasConverter(typ reflect.Type) bool {
+	convertersMu.RLock()
+	_, found := converters[typ]
+	convertersMu.RUnlock()
+	return found || reflect.PointerTo(typ).Implements(textUnmarshalerType)
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// convert converts str to a value of type typ. It uses, in order of
+// preference, a converter registered for typ, the encoding.TextUnmarshaler
+// implementation of *typ, and a conversion based on the kind of typ.
+func convert(str string, typ reflect.Type) (reflect.Value, error) {
+	convertersMu.RLock()
+	fn, found := converters[typ]
+	convertersMu.RUnlock()
+	if found {
+		result, err := fn(str)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(result), nil
+	}
+	if reflect.PointerTo(typ).Implements(textUnmarshalerType) {
+		ptr := reflect.New(typ)
+		if err := ptr.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(str)); err != nil {
+			return reflect.Value{}, err
+		}
+		return ptr.Elem(), nil
+	}
+	if typ.Kind() == reflect.Ptr {
+		elem, err := convert(str, typ.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		ptr := reflect.New(typ.Elem())
+		ptr.Elem().Set(elem)
+		return ptr, nil
+	}
+	result := reflect.New(typ).Elem()
+	switch typ.Kind() {
+	case reflect.String:
+		result.SetString(str)
+	case reflect.Bool:
+		b, err := parseBool(str)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		result.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(str, 10, typ.Bits())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		result.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(str, 10, typ.Bits())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		result.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(str, typ.Bits())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		result.SetFloat(f)
+	default:
+		return reflect.Value{}, fmt.Errorf("no converter registered for %s", typ)
+	}
+	return result, nil
+}
+
+// convertTo converts value, the value of key, to a T.
+func convertTo[T any](key string, value string) (T, error) {
+	var zero T
+	typ := reflect.TypeOf(&zero).Elem()
+	result, err := convert(value, typ)
+	if err != nil {
+		return zero, &ConversionError{Key: key, Value: value, Type: typ, Err: err}
+	}
+	return result.Interface().(T), nil
+}
+
+// Get returns the first element in data.Values[key] converted to a T. T may
+// be a string, bool (see TrueValues), integer or floating point type, time.Duration,
+// time.Time (parsed using DateLayouts), *big.Rat (parsed as a decimal
+// number), a type whose pointer implements encoding.TextUnmarshaler, or any
+// type with a converter registered by RegisterConverter. If the key does
+// not exist, Get returns the zero value of T. If the value cannot be
+// converted, Get returns a *ConversionError.
+func Get[T any](d *Data, key string) (T, error) {
+	if !d.KeyExists(key) || len(d.Values[key]) == 0 {
+		var zero T
+		return zero, nil
+	}
+	return convertTo[T](key, d.Get(key))
+}
+
+// GetAll returns every element in data.Values[key] (or every element of a
+// JSON array) converted to a T. T may be any of the types supported by Get.
+// If the key does not exist, GetAll returns an empty slice. If any value
+// cannot be converted, GetAll returns a *ConversionError for the first one.
+func GetAll[T any](d *Data, key string) ([]T, error) {
+	elements := d.elements(key)
+	results := make([]T, 0, len(elements))
+	for _, element := range elements {
+		result, err := convertTo[T](key, element)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// GetOr is like Get but returns def if the key does not exist or its first
+// value is empty. If the value cannot be converted, GetOr returns def and a
+// *ConversionError.
+func GetOr[T any](d *Data, key string, def T) (T, error) {
+	if d.Get(key) == "" {
+		return def, nil
+	}
+	result, err := convertTo[T](key, d.Get(key))
+	if err != nil {
+		return def, err
+	}
+	return result, nil
+}
+
+// TypeOf will add an error to the Validator if the first element of
+// data.Values[field] cannot be converted to the type of example, e.g.
+// TypeOf("country", CountryCode("")). The conversion is done in the same
+// way as Get, so registered converters and encoding.TextUnmarshaler
+// implementations are honored. If field does not exist, TypeOf does not add
+// an error to the Validator.
+func (v *Validator) TypeOf(field string, example interface{}) *ValidationResult {
+	if !v.data.KeyExists(field) {
+		return validationOk
+	}
+	typ := reflect.TypeOf(example)
+	if _, err := convert(v.data.Get(field), typ); err != nil {
+		return v.addTypeError(field, typeDescription(typ))
+	}
+	return validationOk
+}
+
+// typeDescription returns a description of typ for use in error messages,
+// e.g. "integer" for int64 or "country code" for a type named CountryCode.
+func typeDescription(typ reflect.Type) string {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	switch typ {
+	case reflect.TypeOf(time.Duration(0)):
+		return "duration"
+	case reflect.TypeOf(time.Time{}):
+		return "date"
+	case reflect.TypeOf(big.Rat{}):
+		return "number"
+	}
+	if typ.PkgPath() == "" {
+		switch typ.Kind() {
+		case reflect.Bool:
+			return "boolean"
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return "integer"
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return "non-negative integer"
+		case reflect.Float32, reflect.Float64:
+			return "number"
+		}
+	}
+	if typ.Name() == "" {
+		return typ.String()
+	}
+	return splitCamelCase(typ.Name())
+}
+
+// splitCamelCase splits a name such as "CountryCode" or "UUIDValue" into
+// lower case words, e.g. "country code" or "UUID value". Acronyms keep their
+// case.
+func splitCamelCase(name string) string {
+	runes := []rune(name)
+	words := []string{}
+	start := 0
+	for i := 1; i <= len(runes); i++ {
+		boundary := i == len(runes)
+		if !boundary && unicode.IsUpper(runes[i]) {
+			// either the start of a new word after a lower case letter, or
+			// the last letter of an acronym which starts a new word
+			boundary = unicode.IsLower(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))
+		}
+		if boundary {
+			word := string(runes[start:i])
+			if !isAcronym(word) {
+				word = strings.ToLower(word)
+			}
+			words = append(words, word)
+			start = i
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// isAcronym returns true iff word has more than one letter and they are all
+// upper case.
+func isAcronym(word string) bool {
+	if len(word) < 2 {
+		return false
+	}
+	return strings.ToUpper(word) == word
+}
@@ -0,0 +1,155 @@
+// Copyright 2015 Alex Browne.  All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package forms
+
+import (
+	"errors"
+	"math/big"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testColor int
+
+const (
+	testRed testColor = iota
+	testGreen
+)
+
+type testCountryCode string
+
+func init() {
+	RegisterConverter(func(str string) (testCountryCode, error) {
+		if len(str) != 2 {
+			return "", errors.New("country codes must have two letters")
+		}
+		return testCountryCode(strings.ToUpper(str)), nil
+	})
+}
+
+func TestGenericGet(t *testing.T) {
+	data := newData()
+	data.Values = map[string][]string{
+		"name":    []string{"Bob"},
+		"age":     []string{"25", "33"},
+		"small":   []string{"300"},
+		"ratio":   []string{"0.5"},
+		"retired": []string{"true"},
+		"timeout": []string{"1m"},
+		"born":    []string{"1990-05-17"},
+		"price":   []string{"19.99"},
+		"ip":      []string{"192.168.0.1"},
+		"color":   []string{"1"},
+	}
+
+	if got, err := Get[string](data, "name"); err != nil || got != "Bob" {
+		t.Errorf("name was incorrect. Expected Bob, but got %q (%v).", got, err)
+	}
+	if got, err := Get[int](data, "age"); err != nil || got != 25 {
+		t.Errorf("age was incorrect. Expected 25, but got %d (%v).", got, err)
+	}
+	if got, err := Get[float32](data, "ratio"); err != nil || got != 0.5 {
+		t.Errorf("ratio was incorrect. Expected 0.5, but got %f (%v).", got, err)
+	}
+	if got, err := Get[bool](data, "retired"); err != nil || !got {
+		t.Errorf("retired was incorrect. Expected true, but got %t (%v).", got, err)
+	}
+	if got, err := Get[time.Duration](data, "timeout"); err != nil || got != time.Minute {
+		t.Errorf("timeout was incorrect. Expected 1m0s, but got %s (%v).", got, err)
+	}
+	if got, err := Get[time.Time](data, "born"); err != nil || !got.Equal(time.Date(1990, 5, 17, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("born was incorrect. Expected 1990-05-17, but got %s (%v).", got, err)
+	}
+	if got, err := Get[*big.Rat](data, "price"); err != nil || got.Cmp(big.NewRat(1999, 100)) != 0 {
+		t.Errorf("price was incorrect. Expected 19.99, but got %v (%v).", got, err)
+	}
+	if got, err := Get[net.IP](data, "ip"); err != nil || !got.Equal(net.IPv4(192, 168, 0, 1)) {
+		t.Errorf("ip was incorrect. Expected 192.168.0.1, but got %s (%v).", got, err)
+	}
+	if got, err := Get[testColor](data, "color"); err != nil || got != testGreen {
+		t.Errorf("color was incorrect. Expected %d, but got %d (%v).", testGreen, got, err)
+	}
+	if got, err := Get[*int](data, "age"); err != nil || got == nil || *got != 25 {
+		t.Errorf("age was incorrect. Expected a pointer to 25, but got %v (%v).", got, err)
+	}
+	if got, err := Get[int](data, "missing"); err != nil || got != 0 {
+		t.Errorf("missing was incorrect. Expected 0, but got %d (%v).", got, err)
+	}
+
+	_, err := Get[int8](data, "small")
+	convErr := &ConversionError{}
+	if !errors.As(err, &convErr) {
+		t.Fatalf("Expected a *ConversionError but got %T: %v", err, err)
+	}
+	if convErr.Key != "small" || convErr.Value != "300" || convErr.Type != reflect.TypeOf(int8(0)) {
+		t.Errorf("Unexpected error: %+v", convErr)
+	}
+	if _, err := Get[net.IP](data, "name"); err == nil {
+		t.Error("Expected an error for an invalid IP but got none.")
+	}
+	if _, err := Get[struct{}](data, "name"); err == nil {
+		t.Error("Expected an error for an unsupported type but got none.")
+	}
+}
+
+func TestGenericGetAll(t *testing.T) {
+	data := newData()
+	data.Values = map[string][]string{
+		"ids":   []string{"1", "2", "3"},
+		"mixed": []string{"1", "two"},
+	}
+	if got, err := GetAll[uint](data, "ids"); err != nil || !reflect.DeepEqual(got, []uint{1, 2, 3}) {
+		t.Errorf("ids was incorrect. Expected [1 2 3], but got %v (%v).", got, err)
+	}
+	if got, err := GetAll[int](data, "missing"); err != nil || len(got) != 0 {
+		t.Errorf("missing was incorrect. Expected [], but got %v (%v).", got, err)
+	}
+	if _, err := GetAll[int](data, "mixed"); err == nil || !strings.Contains(err.Error(), `"two"`) {
+		t.Errorf("Expected an error for the value \"two\" but got %v.", err)
+	}
+
+	jsonData := newData()
+	jsonData.jsonBody = []byte(`{"ids":[4,5]}`)
+	if err := parseJSON(jsonData, jsonData.jsonBody); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := GetAll[int64](jsonData, "ids"); err != nil || !reflect.DeepEqual(got, []int64{4, 5}) {
+		t.Errorf("ids was incorrect. Expected [4 5], but got %v (%v).", got, err)
+	}
+}
+
+func TestGenericGetOr(t *testing.T) {
+	data := newData()
+	data.Add("page", "3")
+	data.Add("empty", "")
+	data.Add("word", "three")
+	if got, err := GetOr(data, "page", 1); err != nil || got != 3 {
+		t.Errorf("page was incorrect. Expected 3, but got %d (%v).", got, err)
+	}
+	if got, err := GetOr(data, "missing", 1); err != nil || got != 1 {
+		t.Errorf("missing was incorrect. Expected 1, but got %d (%v).", got, err)
+	}
+	if got, err := GetOr(data, "empty", 1); err != nil || got != 1 {
+		t.Errorf("empty was incorrect. Expected 1, but got %d (%v).", got, err)
+	}
+	if got, err := GetOr(data, "word", 1); err == nil || got != 1 {
+		t.Errorf("word was incorrect. Expected 1 and an error, but got %d (%v).", got, err)
+	}
+}
+
+func TestRegisterConverter(t *testing.T) {
+	data := newData()
+	data.Add("country", "nz")
+	data.Add("invalid", "New Zealand")
+	if got, err := Get[testCountryCode](data, "country"); err != nil || got != "NZ" {
+		t.Errorf("country was incorrect. Expected NZ, but got %q (%v).", got, err)
+	}
+	if _, err := Get[testCountryCode](data, "invalid"); err == nil || !strings.Contains(err.Error(), "two letters") {
+		t.Errorf("Expected the converter's error but got %v.", err)
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2015 Alex Browne.  All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package forms
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// CSVOptions controls how a CSV file is read by ValidateCSV. The zero value
+// reads a standard comma-separated file with no limit on the number of
+// errors.
+type CSVOptions struct {
+	// Comma is the field delimiter. It defaults to ','. Use '\t' for
+	// TSV files.
+	Comma rune
+	// Comment, if not 0, is a character which marks a line as a comment
+	// when it appears at the start of the line.
+	Comment rune
+	// LazyQuotes allows quotes to appear in unquoted fields and
+	// non-doubled quotes to appear in quoted fields.
+	LazyQuotes bool
+	// TrimLeadingSpace causes leading whitespace in each field to
+	// be ignored.
+	TrimLeadingSpace bool
+	// MaxErrors is the maximum number of errors to collect. Once it is
+	// reached, no more rows are read. A MaxErrors of 0 means there is
+	// no limit.
+	MaxErrors int
+}
+
+// CSVError describes a problem with a single row (and optionally a single
+// column) of a CSV file.
+type CSVError struct {
+	// Row is the line number at which the row begins. The header is
+	// always on line 1.
+	Row int
+	// Column is the header name of the column the error is associated
+	// with. It may be empty if the error applies to the whole row.
+	Column string
+	// Message is a user-readable description of the error.
+	Message string
+}
+
+// Error satisfies the error interface.
+func (e *CSVError) Error() string {
+	if e.Column == "" {
+		return fmt.Sprintf("row %d: %s", e.Row, e.Message)
+	}
+	return fmt.Sprintf("row %d, column %s: %s", e.Row, e.Column, e.Message)
+}
+
+// CSVRowFunc is called by ValidateCSV for each row of a CSV file. row is the
+// line number at which the row begins, data holds the values of the row
+// keyed by column header, and val is a Validator for data. Any errors added
+// to val are collected by ValidateCSV once the function returns. Returning
+// a non-nil error stops ValidateCSV immediately.
+type CSVRowFunc func(row int, data *Data, val *Validator) error
+
+// ValidateCSV reads the CSV file associated with key one row at a time. The
+// first row is treated as the header, and each subsequent row is converted
+// to a *Data where the keys are the header names. fn is called for each row
+// so that it can be validated with the usual Validator methods (and, for
+// example, imported if it is valid). ValidateCSV returns all the errors from
+// every row, in order. Rows which have a different number of fields than
+// the header are reported as errors without calling fn.
+//
+// The returned error is only non-nil if the file could not be read or parsed
+// as CSV, or if fn returned an error. If there is no file associated with key,
+// it returns nil, nil. opts may be nil, in which case the defaults described
+// in CSVOptions are used.
+func (d Data) ValidateCSV(key string, opts *CSVOptions, fn CSVRowFunc) ([]*CSVError, error) {
+	header, found := d.Files[key]
+	if !found {
+		return nil, nil
+	}
+	if opts == nil {
+		opts = &CSVOptions{}
+	}
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	if opts.Comma != 0 {
+		reader.Comma = opts.Comma
+	}
+	reader.Comment = opts.Comment
+	reader.LazyQuotes = opts.LazyQuotes
+	reader.TrimLeadingSpace = opts.TrimLeadingSpace
+	// Check the number of fields ourselves so that a bad row can be
+	// reported like any other error instead of aborting.
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	columns, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	columns = append([]string{}, columns...)
+	for i, column := range columns {
+		if i == 0 {
+			// Spreadsheet programs often add a byte order mark.
+			column = strings.TrimPrefix(column, "\uFEFF")
+		}
+		columns[i] = strings.TrimSpace(column)
+	}
+
+	csvErrs := []*CSVError{}
+	full := func() bool {
+		return opts.MaxErrors > 0 && len(csvErrs) >= opts.MaxErrors
+	}
+	for !full() {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return csvErrs, err
+		}
+		row, _ := reader.FieldPos(0)
+		if len(record) != len(columns) {
+			csvErrs = append(csvErrs, &CSVError{
+				Row:     row,
+				Message: fmt.Sprintf("Expected %d columns but got %d.", len(columns), len(record)),
+			})
+			continue
+		}
+		rowData := newData()
+		for i, value := range record {
+			rowData.Add(columns[i], value)
+		}
+		val := rowData.Validator()
+		if err := fn(row, rowData, val); err != nil {
+			return csvErrs, err
+		}
+		for _, result := range val.results {
+			if full() {
+				break
+			}
+			csvErrs = append(csvErrs, &CSVError{
+				Row:     row,
+				Column:  result.field,
+				Message: result.message,
+			})
+		}
+	}
+	return csvErrs, nil
+}
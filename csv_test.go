@@ -0,0 +1,102 @@
+// Copyright 2015 Alex Browne.  All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package forms
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func validateTestCSVRow(row int, data *Data, val *Validator) error {
+	val.Require("name")
+	val.TypeInt("age")
+	return nil
+}
+
+func TestValidateCSV(t *testing.T) {
+	content := "\uFEFFname,age\n" +
+		"bob,25\n" +
+		",thirty\n" +
+		"\"multi\nline\",40\n" +
+		"alice\n" +
+		"jane,x\n"
+	data := newData()
+	fileHeader, err := createTestFileHeader("users.csv", []byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data.AddFile("file", fileHeader)
+
+	names := []string{}
+	csvErrs, err := data.ValidateCSV("file", nil, func(row int, rowData *Data, val *Validator) error {
+		names = append(names, rowData.Get("name"))
+		return validateTestCSVRow(row, rowData, val)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedNames := []string{"bob", "", "multi\nline", "jane"}
+	if !reflect.DeepEqual(names, expectedNames) {
+		t.Errorf("Expected rows with names %q but got %q.", expectedNames, names)
+	}
+	expected := []*CSVError{
+		{Row: 3, Column: "name", Message: "name is required."},
+		{Row: 3, Column: "age", Message: "age must be an integer"},
+		{Row: 6, Message: "Expected 2 columns but got 1."},
+		{Row: 7, Column: "age", Message: "age must be an integer"},
+	}
+	if !reflect.DeepEqual(csvErrs, expected) {
+		t.Errorf("Expected errors %v but got %v.", expected, csvErrs)
+	}
+
+	csvErrs, err = data.ValidateCSV("file", &CSVOptions{MaxErrors: 3}, validateTestCSVRow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(csvErrs) != 3 {
+		t.Errorf("Expected 3 errors because of MaxErrors but got %d.", len(csvErrs))
+	}
+}
+
+func TestValidateTSV(t *testing.T) {
+	data := newData()
+	fileHeader, err := createTestFileHeader("users.tsv", []byte("name\tage\nbob\t25\nbill\told\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data.AddFile("file", fileHeader)
+	csvErrs, err := data.ValidateCSV("file", &CSVOptions{Comma: '\t'}, validateTestCSVRow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(csvErrs) != 1 {
+		t.Fatalf("Expected 1 error but got %d: %v", len(csvErrs), csvErrs)
+	}
+	if got := csvErrs[0].Error(); got != "row 3, column age: age must be an integer" {
+		t.Errorf("Error() was incorrect. Got: %s", got)
+	}
+}
+
+func TestValidateCSVStop(t *testing.T) {
+	data := newData()
+	fileHeader, err := createTestFileHeader("users.csv", []byte("name\nbob\nbill\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data.AddFile("file", fileHeader)
+	stop := errors.New("stop")
+	rows := 0
+	_, err = data.ValidateCSV("file", nil, func(row int, rowData *Data, val *Validator) error {
+		rows++
+		return stop
+	})
+	if err != stop {
+		t.Errorf("Expected the error returned by fn but got %v.", err)
+	}
+	if rows != 1 {
+		t.Errorf("Expected fn to be called once but it was called %d times.", rows)
+	}
+}
@@ -5,14 +5,18 @@
 package forms
 
 import (
+	"crypto"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math/big"
 	"mime/multipart"
 	"net/http"
 	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // DefaultMaxFormSize is the default maximum form size (in bytes) used by the Parse function.
@@ -40,6 +44,20 @@ type Data struct {
 	// jsonBody holds the original body of the request.
 	// Only available for json requests.
 	jsonBody []byte
+	// checksums holds any checksums of files which were computed
+	// while parsing the request, keyed by file key and then by hash.
+	checksums map[string]map[crypto.Hash][]byte
+	// jsonArrays holds the elements of any values which were json arrays
+	// in the request body, keyed by key. Only these keys are expanded by
+	// elements.
+	jsonArrays map[string]jsonArray
+}
+
+// jsonArray holds a json array from the request body, both as the string
+// stored in Values and as its elements converted to strings.
+type jsonArray struct {
+	value    string
+	elements []string
 }
 
 func newData() *Data {
@@ -49,16 +67,94 @@ func newData() *Data {
 	}
 }
 
+// ParseOptions controls how ParseWithOptions parses a request.
+type ParseOptions struct {
+	// MaxMemory is the maximum number of bytes of a multipart form which
+	// will be stored in memory. Any remaining file parts are stored on disk
+	// in temporary files. If MaxMemory is 0, DefaultMaxFormSize is used.
+	MaxMemory int64
+	// Hashes is a list of hash functions (e.g. crypto.SHA256) to compute
+	// over the contents of each uploaded file while the request body is
+	// being parsed. The results can be accessed with Data.FileChecksum
+	// without reading the files again.
+	Hashes []crypto.Hash
+	// OnProgress, if not nil, is called each time a chunk of the request
+	// body is read. For multipart forms, the progress includes the name
+	// of the part currently being read. It is called from the goroutine
+	// which is parsing the request, so it should return quickly.
+	OnProgress func(progress Progress)
+	// ReadTimeout, if not 0, is the maximum amount of time allowed for
+	// reading the request body. If it is exceeded, parsing is aborted
+	// with a *SlowUploadError. The deadline is checked each time data is
+	// received, so a connection which stops sending data altogether
+	// should be handled with http.Server.ReadTimeout.
+	ReadTimeout time.Duration
+	// MinThroughput, if not 0, is the minimum average number of bytes per
+	// second at which the request body must be received. It is enforced
+	// once ThroughputGracePeriod has passed. If the body is received more
+	// slowly, parsing is aborted with a *SlowUploadError.
+	MinThroughput int64
+	// ThroughputGracePeriod is the amount of time to wait before enforcing
+	// MinThroughput. If it is 0, DefaultThroughputGracePeriod is used.
+	ThroughputGracePeriod time.Duration
+}
+
 // ParseMax parses the request body and url query parameters into
 // Data. The content in the body of the request has a higher priority,
 // will be added to Data first, and will be the result of any operation
 // which gets the first element for a given key (e.g. Get, GetInt, or GetBool).
 func ParseMax(req *http.Request, max int64) (*Data, error) {
+	// max is passed through unchanged, so that 0 still means no file data
+	// is kept in memory
+	return parse(req, &ParseOptions{}, max)
+}
+
+// ParseWithOptions is like ParseMax but allows additional options to be
+// specified. opts may be nil, in which case the defaults described in
+// ParseOptions are used.
+func ParseWithOptions(req *http.Request, opts *ParseOptions) (*Data, error) {
+	if opts == nil {
+		opts = &ParseOptions{}
+	}
+	max := opts.MaxMemory
+	if max == 0 {
+		max = DefaultMaxFormSize
+	}
+	return parse(req, opts, max)
+}
+
+// parse parses req according to opts, storing at most max bytes of a
+// multipart form in memory. opts.MaxMemory is ignored.
+func parse(req *http.Request, opts *ParseOptions, max int64) (*Data, error) {
 	data := newData()
+	var progress *progressReader
+	if opts.usesProgressReader() {
+		progress = newProgressReader(req, opts)
+	}
 	contentType := req.Header.Get("Content-Type")
 	if strings.Contains(contentType, "multipart/form-data") {
-		if err := req.ParseMultipartForm(max); err != nil {
-			return nil, err
+		partFuncs := []partFunc{}
+		if progress != nil {
+			partFuncs = append(partFuncs, progress.observePart)
+		}
+		if len(opts.Hashes) > 0 {
+			for _, hash := range opts.Hashes {
+				if !hash.Available() {
+					return nil, fmt.Errorf("forms: hash function %v is not available", hash)
+				}
+			}
+			partFuncs = append(partFuncs, newChecksumCollector(data, opts.Hashes))
+		}
+		var observer *partObserver
+		if len(partFuncs) > 0 {
+			observer = observeMultipart(req, chainPartFuncs(partFuncs...))
+		}
+		err := req.ParseMultipartForm(max)
+		if observer != nil {
+			observer.close()
+		}
+		if err != nil {
+			return nil, progress.abortErr(err)
 		}
 		for key, vals := range req.MultipartForm.Value {
 			for _, val := range vals {
@@ -72,7 +168,7 @@ func ParseMax(req *http.Request, max int64) (*Data, error) {
 		}
 	} else if strings.Contains(contentType, "form-urlencoded") {
 		if err := req.ParseForm(); err != nil {
-			return nil, err
+			return nil, progress.abortErr(err)
 		}
 		for key, vals := range req.PostForm {
 			for _, val := range vals {
@@ -82,10 +178,10 @@ func ParseMax(req *http.Request, max int64) (*Data, error) {
 	} else if strings.Contains(contentType, "application/json") {
 		body, err := ioutil.ReadAll(req.Body)
 		if err != nil {
-			return nil, err
+			return nil, progress.abortErr(err)
 		}
 		data.jsonBody = body
-		if err := parseJSON(data.Values, data.jsonBody); err != nil {
+		if err := parseJSON(data, data.jsonBody); err != nil {
 			return nil, err
 		}
 	}
@@ -112,7 +208,7 @@ func CreateFromMap(m map[string]string) *Data {
 	return data
 }
 
-func parseJSON(values url.Values, body []byte) error {
+func parseJSON(data *Data, body []byte) error {
 	if len(body) == 0 {
 		// don't attempt to parse empty bodies
 		return nil
@@ -121,28 +217,48 @@ func parseJSON(values url.Values, body []byte) error {
 	if err := json.Unmarshal(body, &rawData); err != nil {
 		return err
 	}
-	// Whatever the underlying type is, we need to convert it to a
-	// string. There are only a few possible types, so we can just
-	// do a type switch over the possibilities.
 	for key, val := range rawData {
-		switch val.(type) {
-		case string, bool, float64:
-			values.Add(key, fmt.Sprint(val))
-		case nil:
-			values.Add(key, "")
-		case map[string]interface{}, []interface{}:
-			// for more complicated data structures, convert back to
-			// a JSON string and let user decide how to unmarshal
-			jsonVal, err := json.Marshal(val)
-			if err != nil {
-				return err
+		str, err := jsonValueToString(val)
+		if err != nil {
+			return err
+		}
+		data.Values.Add(key, str)
+		if arr, ok := val.([]interface{}); ok {
+			elems := make([]string, len(arr))
+			for i, elem := range arr {
+				if elems[i], err = jsonValueToString(elem); err != nil {
+					return err
+				}
+			}
+			if data.jsonArrays == nil {
+				data.jsonArrays = map[string]jsonArray{}
 			}
-			values.Add(key, string(jsonVal))
+			data.jsonArrays[key] = jsonArray{value: str, elements: elems}
 		}
 	}
 	return nil
 }
 
+// jsonValueToString converts a value decoded from json to a string.
+func jsonValueToString(val interface{}) (string, error) {
+	// Whatever the underlying type is, we need to convert it to a
+	// string. There are only a few possible types, so we can just
+	// do a type switch over the possibilities.
+	switch val.(type) {
+	case string, bool, float64:
+		return fmt.Sprint(val), nil
+	case map[string]interface{}, []interface{}:
+		// for more complicated data structures, convert back to
+		// a JSON string and let user decide how to unmarshal
+		jsonVal, err := json.Marshal(val)
+		if err != nil {
+			return "", err
+		}
+		return string(jsonVal), nil
+	}
+	return "", nil
+}
+
 // Add adds the value to key. It appends to any existing values associated with key.
 func (d *Data) Add(key string, value string) {
 	d.Values.Add(key, value)
@@ -156,6 +272,7 @@ func (d *Data) AddFile(key string, file *multipart.FileHeader) {
 // Del deletes the values associated with key.
 func (d *Data) Del(key string) {
 	d.Values.Del(key)
+	delete(d.jsonArrays, key)
 }
 
 // DelFile deletes the file associated with key (if any).
@@ -188,6 +305,7 @@ func (d Data) GetFile(key string) *multipart.FileHeader {
 // Set sets the key to value. It replaces any existing values.
 func (d *Data) Set(key string, value string) {
 	d.Values.Set(key, value)
+	delete(d.jsonArrays, key)
 }
 
 // KeyExists returns true iff data.Values[key] exists. When parsing a request body, the key
@@ -232,19 +350,95 @@ func (d Data) GetFloat(key string) float64 {
 	}
 }
 
-// GetBool returns the first element in data[key] converted to a bool.
-func (d Data) GetBool(key string) bool {
+// GetInt64 returns the first element in data[key] converted to an int64.
+// Unlike GetInt, the result has the same range on every platform.
+func (d Data) GetInt64(key string) int64 {
+	if !d.KeyExists(key) || len(d.Values[key]) == 0 {
+		return 0
+	}
+	str := d.Get(key)
+	if result, err := strconv.ParseInt(str, 10, 64); err != nil {
+		panic(err)
+	} else {
+		return result
+	}
+}
+
+// GetUint64 returns the first element in data[key] converted to a uint64.
+func (d Data) GetUint64(key string) uint64 {
+	if !d.KeyExists(key) || len(d.Values[key]) == 0 {
+		return 0
+	}
+	str := d.Get(key)
+	if result, err := strconv.ParseUint(str, 10, 64); err != nil {
+		panic(err)
+	} else {
+		return result
+	}
+}
+
+// GetDuration returns the first element in data[key] converted to a
+// time.Duration. The value should be formatted as expected by
+// time.ParseDuration, e.g. "1h30m".
+func (d Data) GetDuration(key string) time.Duration {
+	if !d.KeyExists(key) || len(d.Values[key]) == 0 {
+		return 0
+	}
+	str := d.Get(key)
+	if result, err := time.ParseDuration(str); err != nil {
+		panic(err)
+	} else {
+		return result
+	}
+}
+
+// GetDecimal returns the first element in data[key], which should be a
+// decimal number such as "19.99", converted to an exact *big.Rat. Unlike
+// GetFloat, no precision is lost, which makes it suitable for values like
+// monetary amounts.
+func (d Data) GetDecimal(key string) *big.Rat {
 	if !d.KeyExists(key) || len(d.Values[key]) == 0 {
-		return false
+		return new(big.Rat)
 	}
 	str := d.Get(key)
-	if result, err := strconv.ParseBool(str); err != nil {
+	if result, err := parseDecimal(str); err != nil {
 		panic(err)
 	} else {
 		return result
 	}
 }
 
+// decimalRegex matches a plain decimal number with an optional sign,
+// fraction and exponent.
+var decimalRegex = regexp.MustCompile(`^[+-]?([0-9]+(\.[0-9]*)?|\.[0-9]+)([eE][+-]?[0-9]+)?$`)
+
+// parseDecimal converts a decimal number such as "-19.99" or "1.5e3" to
+// a *big.Rat. Unlike big.Rat.SetString, fractions such as "1/3", prefixes
+// such as "0x" and underscores such as "1_000" are not accepted.
+func parseDecimal(str string) (*big.Rat, error) {
+	str = strings.TrimSpace(str)
+	if !decimalRegex.MatchString(str) {
+		return nil, fmt.Errorf("forms: invalid decimal %q", str)
+	}
+	result, ok := new(big.Rat).SetString(str)
+	if !ok {
+		return nil, fmt.Errorf("forms: invalid decimal %q", str)
+	}
+	return result, nil
+}
+
+// GetBool returns the value of data[key] converted to a bool using
+// TrueValues and FalseValues, so e.g. "on", "yes" and "1" are all true. If
+// there is more than one value for key, the result is true if any of them
+// is true, which supports checkboxes preceded by a hidden field with the
+// same name. If the key does not exist, e.g. because a checkbox was
+// unchecked, GetBool returns false. Use LookupBool to tell the difference.
+// GetBool panics if any value for key is not a valid boolean.
+func (d Data) GetBool(key string) bool {
+	value, _ := d.LookupBool(key)
+	return value
+}
+
 // GetBytes returns the first element in data[key] converted to a slice of bytes.
 func (d Data) GetBytes(key string) []byte {
 	return []byte(d.Get(key))
@@ -275,6 +469,20 @@ func (d Data) GetStringsSplit(key string, delim string) []string {
 	return strings.Split(d.Values[key][0], delim)
 }
 
+// elements returns every value associated with key. If the request body was
+// json, the value for key was a json array, and it has not been replaced or
+// added to, the elements of the array are returned instead, converted to
+// strings in the same way as other json values. A json string which merely
+// looks like an array is not expanded.
+func (d Data) elements(key string) []string {
+	vals := d.Values[key]
+	arr, found := d.jsonArrays[key]
+	if !found || len(vals) != 1 || vals[0] != arr.value {
+		return vals
+	}
+	return arr.elements
+}
+
 // BindJSON binds v to the json data in the request body. It calls json.Unmarshal and
 // sets the value of v.
 func (d Data) BindJSON(v interface{}) error {
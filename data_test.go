@@ -9,6 +9,8 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
+	"math/big"
 	"mime/multipart"
 	"net/http"
 	"net/url"
@@ -16,6 +18,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestGet(t *testing.T) {
@@ -117,6 +120,172 @@ func TestGetFloat(t *testing.T) {
 	}
 }
 
+func TestGetInt64(t *testing.T) {
+	data := newData()
+	data.Values = map[string][]string{
+		"id":       []string{"9007199254740993"},
+		"negative": []string{"-9223372036854775808"},
+	}
+
+	table := []struct {
+		key      string
+		expected int64
+	}{
+		{
+			key:      "id",
+			expected: 9007199254740993,
+		},
+		{
+			key:      "negative",
+			expected: math.MinInt64,
+		},
+		{
+			key:      "missing",
+			expected: 0,
+		},
+	}
+
+	for _, test := range table {
+		got := data.GetInt64(test.key)
+		if got != test.expected {
+			t.Errorf("%s was incorrect. Expected %d, but got %d.\n", test.key, test.expected, got)
+		}
+	}
+}
+
+func TestGetUint64(t *testing.T) {
+	data := newData()
+	data.Values = map[string][]string{
+		"quantity": []string{"18446744073709551615"},
+		"negative": []string{"-1"},
+	}
+
+	table := []struct {
+		key      string
+		expected uint64
+	}{
+		{
+			key:      "quantity",
+			expected: math.MaxUint64,
+		},
+		{
+			key:      "missing",
+			expected: 0,
+		},
+	}
+
+	for _, test := range table {
+		got := data.GetUint64(test.key)
+		if got != test.expected {
+			t.Errorf("%s was incorrect. Expected %d, but got %d.\n", test.key, test.expected, got)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected GetUint64 to panic for a negative number but it did not.")
+		}
+	}()
+	data.GetUint64("negative")
+}
+
+func TestGetDuration(t *testing.T) {
+	data := newData()
+	data.Values = map[string][]string{
+		"timeout": []string{"1h30m"},
+		"delay":   []string{"250ms"},
+	}
+
+	table := []struct {
+		key      string
+		expected time.Duration
+	}{
+		{
+			key:      "timeout",
+			expected: 90 * time.Minute,
+		},
+		{
+			key:      "delay",
+			expected: 250 * time.Millisecond,
+		},
+		{
+			key:      "missing",
+			expected: 0,
+		},
+	}
+
+	for _, test := range table {
+		got := data.GetDuration(test.key)
+		if got != test.expected {
+			t.Errorf("%s was incorrect. Expected %s, but got %s.\n", test.key, test.expected, got)
+		}
+	}
+}
+
+func TestGetDecimal(t *testing.T) {
+	data := newData()
+	data.Values = map[string][]string{
+		"price": []string{"19.99"},
+		"tiny":  []string{"1e-30"},
+	}
+
+	tiny, _ := new(big.Rat).SetString("1e-30")
+	table := []struct {
+		key      string
+		expected *big.Rat
+	}{
+		{
+			key:      "price",
+			expected: big.NewRat(1999, 100),
+		},
+		{
+			key:      "tiny",
+			expected: tiny,
+		},
+		{
+			key:      "missing",
+			expected: new(big.Rat),
+		},
+	}
+
+	for _, test := range table {
+		got := data.GetDecimal(test.key)
+		if got.Cmp(test.expected) != 0 {
+			t.Errorf("%s was incorrect. Expected %s, but got %s.\n", test.key, test.expected.RatString(), got.RatString())
+		}
+	}
+}
+
+func TestParseDecimal(t *testing.T) {
+	table := []struct {
+		str      string
+		expected *big.Rat
+	}{
+		{"19.99", big.NewRat(1999, 100)},
+		{" -19.99 ", big.NewRat(-1999, 100)},
+		{"+5", big.NewRat(5, 1)},
+		{"5.", big.NewRat(5, 1)},
+		{".5", big.NewRat(1, 2)},
+		{"1.5e3", big.NewRat(1500, 1)},
+		{"25E-2", big.NewRat(1, 4)},
+		{"007", big.NewRat(7, 1)},
+	}
+	for _, test := range table {
+		got, err := parseDecimal(test.str)
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %s", test.str, err)
+		} else if got.Cmp(test.expected) != 0 {
+			t.Errorf("parseDecimal(%q) was incorrect. Expected %s, but got %s.", test.str, test.expected.RatString(), got.RatString())
+		}
+	}
+	for _, str := range []string{"1/3", "", "abc", "1.2.3", ".", "-", "1e", "e5",
+		"0x10", "0X10", "0b101", "0o17", "1_000", "0x1p-2", "Inf", "NaN", "1 000"} {
+		if _, err := parseDecimal(str); err == nil {
+			t.Errorf("Expected an error for %q but got none.", str)
+		}
+	}
+}
+
 func TestGetBool(t *testing.T) {
 	data := newData()
 	data.Values = map[string][]string{
@@ -372,6 +541,27 @@ func TestParseMultipart(t *testing.T) {
 	}
 }
 
+func TestParseMaxZero(t *testing.T) {
+	req := createTestMultipartRequest(t, map[string]string{"name": "Bob"}, []testFilePart{
+		{key: "file", filename: "test_file.txt", content: []byte("Hello!")},
+	})
+	d, err := ParseMax(req, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Get("name") != "Bob" {
+		t.Errorf(`Expected name to be "Bob" but got %q.`, d.Get("name"))
+	}
+	file, err := d.GetFile("file").Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, ok := file.(*os.File); !ok {
+		t.Errorf("Expected ParseMax(req, 0) to store the file on disk but got a %T.", file)
+	}
+}
+
 // Used for testing multipart and urlencoded form data, since both tests expect the same data
 // to be present.
 func testBasicFormFields(t *testing.T, d *Data) {
@@ -0,0 +1,178 @@
+// Copyright 2015 Alex Browne.  All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package forms
+
+import (
+	"fmt"
+	"time"
+)
+
+// DateLayout is the layout of an ISO 8601 date without a time.
+const DateLayout = "2006-01-02"
+
+// DateLayouts are the layouts tried, in order, by GetTime, GetTimeIn and
+// the date validation methods when no layouts are given. They include
+// RFC 3339 (with or without fractional seconds), ISO 8601 dates and times
+// without a time zone, and ISO 8601 dates without a time. Values which do
+// not include a time zone are interpreted as UTC unless a location is given.
+var DateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04",
+	DateLayout,
+}
+
+// parseTime parses str using the first layout in layouts which matches.
+// If layouts is empty, DateLayouts is used. Values without a time zone
+// are interpreted in loc.
+func parseTime(str string, loc *time.Location, layouts []string) (time.Time, error) {
+	if len(layouts) == 0 {
+		layouts = DateLayouts
+	}
+	var firstErr error
+	for _, layout := range layouts {
+		t, err := time.ParseInLocation(layout, str, loc)
+		if err == nil {
+			return t, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return time.Time{}, firstErr
+}
+
+// GetTime returns the first element in data[key] converted to a time.Time
+// using the first of layouts which matches. If no layouts are given,
+// DateLayouts is used. Values without a time zone are interpreted as UTC.
+func (d Data) GetTime(key string, layouts ...string) time.Time {
+	return d.GetTimeIn(key, time.UTC, layouts...)
+}
+
+// GetTimeIn is like GetTime but values without a time zone are interpreted
+// in loc. Values which include a time zone keep it.
+func (d Data) GetTimeIn(key string, loc *time.Location, layouts ...string) time.Time {
+	if !d.KeyExists(key) || len(d.Values[key]) == 0 {
+		return time.Time{}
+	}
+	if result, err := parseTime(d.Get(key), loc, layouts); err != nil {
+		panic(err)
+	} else {
+		return result
+	}
+}
+
+// GetDate returns the first element in data[key], which should be an
+// ISO 8601 date such as "2015-03-21", converted to a time.Time at
+// midnight UTC.
+func (d Data) GetDate(key string) time.Time {
+	return d.GetTime(key, DateLayout)
+}
+
+// TypeDate will add an error to the Validator if the first element of
+// data.Values[field] cannot be converted to a time.Time using any of
+// layouts. If no layouts are given, DateLayouts is used.
+func (v *Validator) TypeDate(field string, layouts ...string) *ValidationResult {
+	if _, err := parseTime(v.data.Get(field), time.UTC, layouts); err != nil {
+		return v.addTypeError(field, "date")
+	}
+	return validationOk
+}
+
+// Before will add an error to the Validator if the first element of
+// data.Values[field] is not before t or if it cannot be converted to
+// a time.Time using DateLayouts.
+func (v *Validator) Before(field string, t time.Time) *ValidationResult {
+	got, err := parseTime(v.data.Get(field), time.UTC, nil)
+	if err != nil {
+		return v.addTypeError(field, "date")
+	}
+	if !got.Before(t) {
+		msg := fmt.Sprintf("%s must be before %s.", field, formatTime(t))
+		return v.AddError(field, msg)
+	}
+	return validationOk
+}
+
+// After will add an error to the Validator if the first element of
+// data.Values[field] is not after t or if it cannot be converted to
+// a time.Time using DateLayouts.
+func (v *Validator) After(field string, t time.Time) *ValidationResult {
+	got, err := parseTime(v.data.Get(field), time.UTC, nil)
+	if err != nil {
+		return v.addTypeError(field, "date")
+	}
+	if !got.After(t) {
+		msg := fmt.Sprintf("%s must be after %s.", field, formatTime(t))
+		return v.AddError(field, msg)
+	}
+	return validationOk
+}
+
+// DateRange will add an error to the Validator if the first element of
+// data.Values[field] is before min or after max, or if it cannot be
+// converted to a time.Time using DateLayouts.
+func (v *Validator) DateRange(field string, min time.Time, max time.Time) *ValidationResult {
+	got, err := parseTime(v.data.Get(field), time.UTC, nil)
+	if err != nil {
+		return v.addTypeError(field, "date")
+	}
+	if got.Before(min) || got.After(max) {
+		msg := fmt.Sprintf("%s must be between %s and %s.", field, formatTime(min), formatTime(max))
+		return v.AddError(field, msg)
+	}
+	return validationOk
+}
+
+// BeforeField will add an error to the Validator if the first element of
+// data.Values[field1] is not before the first element of
+// data.Values[field2], e.g. BeforeField("start_date", "end_date"). If
+// field2 is empty, it does not add an error to the Validator. The error is
+// associated with whichever field could not be converted to a time.Time,
+// or with field1 otherwise.
+func (v *Validator) BeforeField(field1 string, field2 string) *ValidationResult {
+	return v.compareTimeFields(field1, field2, "before", func(t1, t2 time.Time) bool {
+		return t1.Before(t2)
+	})
+}
+
+// AfterField will add an error to the Validator if the first element of
+// data.Values[field1] is not after the first element of
+// data.Values[field2]. If field2 is empty, it does not add an error to the
+// Validator. The error is associated with whichever field could not be
+// converted to a time.Time, or with field1 otherwise.
+func (v *Validator) AfterField(field1 string, field2 string) *ValidationResult {
+	return v.compareTimeFields(field1, field2, "after", func(t1, t2 time.Time) bool {
+		return t1.After(t2)
+	})
+}
+
+func (v *Validator) compareTimeFields(field1 string, field2 string, explanation string, ok func(t1, t2 time.Time) bool) *ValidationResult {
+	if v.isBlank(field2) {
+		return validationOk
+	}
+	t1, err := parseTime(v.data.Get(field1), time.UTC, nil)
+	if err != nil {
+		return v.addTypeError(field1, "date")
+	}
+	t2, err := parseTime(v.data.Get(field2), time.UTC, nil)
+	if err != nil {
+		return v.addTypeError(field2, "date")
+	}
+	if !ok(t1, t2) {
+		msg := fmt.Sprintf("%s must be %s %s.", field1, explanation, field2)
+		return v.AddError(field1, msg)
+	}
+	return validationOk
+}
+
+// formatTime formats t for use in an error message. Times at midnight UTC
+// are formatted as dates only.
+func formatTime(t time.Time) string {
+	if t.Location() == time.UTC && t.Equal(t.Truncate(24*time.Hour)) {
+		return t.Format(DateLayout)
+	}
+	return t.Format(time.RFC3339)
+}
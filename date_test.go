@@ -0,0 +1,134 @@
+// Copyright 2015 Alex Browne.  All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package forms
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGetTime(t *testing.T) {
+	data := newData()
+	data.Values = map[string][]string{
+		"rfc3339":  []string{"2015-03-21T10:30:00-07:00"},
+		"nano":     []string{"2015-03-21T10:30:00.123Z"},
+		"local":    []string{"2015-03-21T10:30:00"},
+		"date":     []string{"2015-03-21", "2016-01-01"},
+		"custom":   []string{"03/21/2015"},
+		"notADate": []string{"yesterday"},
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("Could not load time zone: %s", err)
+	}
+
+	table := []struct {
+		key      string
+		got      time.Time
+		expected time.Time
+	}{
+		{"rfc3339", data.GetTime("rfc3339"), time.Date(2015, 3, 21, 17, 30, 0, 0, time.UTC)},
+		{"nano", data.GetTime("nano"), time.Date(2015, 3, 21, 10, 30, 0, 123000000, time.UTC)},
+		{"local", data.GetTime("local"), time.Date(2015, 3, 21, 10, 30, 0, 0, time.UTC)},
+		{"local in New York", data.GetTimeIn("local", newYork), time.Date(2015, 3, 21, 10, 30, 0, 0, newYork)},
+		{"rfc3339 in New York", data.GetTimeIn("rfc3339", newYork), time.Date(2015, 3, 21, 17, 30, 0, 0, time.UTC)},
+		{"date", data.GetDate("date"), time.Date(2015, 3, 21, 0, 0, 0, 0, time.UTC)},
+		{"custom", data.GetTime("custom", "01/02/2006"), time.Date(2015, 3, 21, 0, 0, 0, 0, time.UTC)},
+		{"missing", data.GetTime("missing"), time.Time{}},
+	}
+	for _, test := range table {
+		if !test.got.Equal(test.expected) {
+			t.Errorf("%s was incorrect. Expected %v, but got %v.", test.key, test.expected, test.got)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected GetTime to panic for an invalid date but it did not.")
+		}
+	}()
+	data.GetTime("notADate")
+}
+
+func TestTypeDate(t *testing.T) {
+	data := newData()
+	data.Add("date", "2015-03-21")
+	data.Add("time", "2015-03-21T10:30:00Z")
+	data.Add("custom", "21.03.2015")
+	data.Add("notADate", "yesterday")
+	val := data.Validator()
+	val.TypeDate("date")
+	val.TypeDate("time")
+	val.TypeDate("custom", "02.01.2006")
+	if val.HasErrors() {
+		t.Errorf("Expected no errors but got errors: %v", val.Messages())
+	}
+
+	val.TypeDate("notADate")
+	val.TypeDate("custom")
+	if len(val.Messages()) != 2 {
+		t.Errorf("Expected 2 validation errors but got %d.", len(val.Messages()))
+	}
+}
+
+func TestDateComparisons(t *testing.T) {
+	data := newData()
+	data.Add("date", "2015-03-21")
+	data.Add("time", "2015-03-21T10:30:00+02:00")
+	data.Add("notADate", "yesterday")
+	march20 := time.Date(2015, 3, 20, 0, 0, 0, 0, time.UTC)
+	march22 := time.Date(2015, 3, 22, 0, 0, 0, 0, time.UTC)
+
+	val := data.Validator()
+	val.After("date", march20)
+	val.Before("date", march22)
+	val.DateRange("date", march20, march22)
+	val.DateRange("time", march20, march22)
+	if val.HasErrors() {
+		t.Errorf("Expected no errors but got errors: %v", val.Messages())
+	}
+
+	val.Before("date", march20)
+	val.After("time", time.Date(2015, 3, 21, 10, 0, 0, 0, time.UTC))
+	val.DateRange("date", march22, march22.AddDate(0, 1, 0))
+	val.Before("notADate", march22)
+	expected := []string{
+		"date must be before 2015-03-20.",
+		"time must be after 2015-03-21T10:00:00Z.",
+		"date must be between 2015-03-22 and 2015-04-22.",
+		"notADate must be a date",
+	}
+	if !reflect.DeepEqual(val.Messages(), expected) {
+		t.Errorf("Expected messages %v but got %v.", expected, val.Messages())
+	}
+}
+
+func TestDateFieldComparisons(t *testing.T) {
+	data := newData()
+	data.Add("start_date", "2015-03-21")
+	data.Add("end_date", "2015-03-28")
+	data.Add("notADate", "tomorrow")
+
+	val := data.Validator()
+	val.BeforeField("start_date", "end_date")
+	val.AfterField("end_date", "start_date")
+	val.BeforeField("start_date", "missing")
+	if val.HasErrors() {
+		t.Errorf("Expected no errors but got errors: %v", val.Messages())
+	}
+
+	val.BeforeField("end_date", "start_date")
+	val.AfterField("start_date", "start_date")
+	val.BeforeField("start_date", "notADate")
+	expected := map[string][]string{
+		"end_date":   {"end_date must be before start_date."},
+		"start_date": {"start_date must be after start_date."},
+		"notADate":   {"notADate must be a date"},
+	}
+	if !reflect.DeepEqual(val.ErrorMap(), expected) {
+		t.Errorf("Expected errors %v but got %v.", expected, val.ErrorMap())
+	}
+}
@@ -0,0 +1,312 @@
+// Copyright 2015 Alex Browne.  All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package forms
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/mail"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// MaxEmailLength is the maximum length of an email address in bytes, as
+	// limited by the maximum length of a path in RFC 5321.
+	MaxEmailLength = 254
+	// MaxEmailLocalLength is the maximum length of the local part of an
+	// email address (the part before the @) in bytes.
+	MaxEmailLocalLength = 64
+)
+
+// EmailOptions controls how Email validates email addresses.
+type EmailOptions struct {
+	// AllowUnicode allows internationalized email addresses, which contain
+	// non-ASCII characters in the local part or the domain (an IDN such as
+	// "bücher.example"). Lengths are checked against the ASCII form of
+	// the domain.
+	AllowUnicode bool
+	// AllowDisplayName allows addresses with a display name or angle
+	// brackets, such as "Bob <bob@example.com>".
+	AllowDisplayName bool
+	// Blocklist, if not nil, is used to reject addresses at blocked
+	// domains, such as disposable email providers.
+	Blocklist DomainBlocklist
+}
+
+// Email will add an error to the Validator if the first element of
+// data.Values[field] is not an email address as defined by RFC 5322 whose
+// domain is a hostname with at least two labels, or if it is longer than
+// allowed by RFC 5321. opts may be nil, in which case only plain ASCII
+// addresses without a display name are accepted. The error has the code
+// "email", or "email_blocked" if the domain is in opts.Blocklist.
+//
+// Unlike MatchEmail, Email uses net/mail, so quoted local parts such as
+// "\"john smith\"@example.com" are accepted.
+func (v *Validator) Email(field string, opts *EmailOptions) *ValidationResult {
+	if opts == nil {
+		opts = &EmailOptions{}
+	}
+	_, domain, err := parseEmail(v.data.Get(field), opts)
+	if err != nil {
+		return v.addFormatError(field, "email", "email address")
+	}
+	if opts.Blocklist != nil && opts.Blocklist.Blocked(domain) {
+		msg := fmt.Sprintf("%s cannot be an address at %s.", field, domain)
+		return v.AddError(field, msg).Code("email_blocked")
+	}
+	return validationOk
+}
+
+// parseEmail parses str as an email address according to opts, and
+// returns its local part and its domain in lower case ASCII.
+func parseEmail(str string, opts *EmailOptions) (local string, domain string, err error) {
+	errInvalid := errors.New("forms: invalid email address")
+	addr, err := mail.ParseAddress(str)
+	if err != nil {
+		return "", "", err
+	}
+	// an address with a display name or angle brackets always ends with
+	// the closing angle bracket
+	if !opts.AllowDisplayName && (addr.Name != "" || strings.HasSuffix(strings.TrimSpace(str), ">")) {
+		return "", "", errInvalid
+	}
+	i := strings.LastIndex(addr.Address, "@")
+	local, domain = addr.Address[:i], addr.Address[i+1:]
+	if !opts.AllowUnicode && (!isASCII(local) || !isASCII(domain)) {
+		return "", "", errInvalid
+	}
+	domain, err = domainToASCII(strings.ToLower(domain))
+	if err != nil {
+		return "", "", err
+	}
+	if !isHostname(domain) || !strings.Contains(strings.TrimSuffix(domain, "."), ".") {
+		return "", "", errInvalid
+	}
+	if len(local) > MaxEmailLocalLength || len(local)+1+len(domain) > MaxEmailLength {
+		return "", "", errInvalid
+	}
+	return local, domain, nil
+}
+
+// NormalizeEmail returns address in a canonical form, so that it can be
+// compared with other addresses or used as a key. The display name, if
+// any, is removed and the domain is converted to lower case ASCII. The
+// case of the local part is preserved because, strictly speaking, it is
+// significant. If stripTag is true, any "+tag" suffix of the local part is
+// removed too, e.g. "Bob+news@Example.COM" becomes "Bob@example.com". Not
+// every mail provider treats "+" this way, so only strip tags when
+// comparing addresses for providers which do. NormalizeEmail returns an
+// error if address is not an email address.
+func NormalizeEmail(address string, stripTag bool) (string, error) {
+	local, domain, err := parseEmail(address, &EmailOptions{
+		AllowUnicode:     true,
+		AllowDisplayName: true,
+	})
+	if err != nil {
+		return "", err
+	}
+	if stripTag {
+		if i := strings.Index(local, "+"); i > 0 {
+			local = local[:i]
+		}
+	}
+	if strings.ContainsAny(local, " \"(),:;<>@[\\]") {
+		local = fmt.Sprintf("%q", local)
+	}
+	return local + "@" + domain, nil
+}
+
+// DomainBlocklist decides whether email addresses at a domain should be
+// rejected, e.g. because the domain belongs to a disposable email provider.
+type DomainBlocklist interface {
+	// Blocked returns true iff addresses at domain should be rejected.
+	// domain is in lower case ASCII.
+	Blocked(domain string) bool
+}
+
+// DomainSet is a DomainBlocklist which blocks the domains it contains and
+// all of their subdomains. Keys must be in lower case ASCII.
+type DomainSet map[string]bool
+
+// NewDomainSet returns a DomainSet containing domains.
+func NewDomainSet(domains ...string) DomainSet {
+	set := DomainSet{}
+	for _, domain := range domains {
+		set[strings.ToLower(strings.TrimSuffix(domain, "."))] = true
+	}
+	return set
+}
+
+// LoadDomainSet reads a DomainSet from the file at path, which should
+// contain one domain per line. Blank lines and lines starting with "#" are
+// ignored, so lists of disposable email domains in the common format can
+// be used as they are.
+func LoadDomainSet(path string) (DomainSet, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	set := DomainSet{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		set[strings.ToLower(strings.TrimSuffix(line, "."))] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// Blocked returns true iff domain or any of its parent domains is in s.
+func (s DomainSet) Blocked(domain string) bool {
+	domain = strings.TrimSuffix(domain, ".")
+	for {
+		if s[domain] {
+			return true
+		}
+		i := strings.Index(domain, ".")
+		if i == -1 {
+			return false
+		}
+		domain = domain[i+1:]
+	}
+}
+
+// isASCII returns true iff str contains only ASCII characters.
+func isASCII(str string) bool {
+	for i := 0; i < len(str); i++ {
+		if str[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// domainToASCII converts any labels of domain which contain non-ASCII
+// characters to punycode, e.g. "bücher.example" becomes
+// "xn--bcher-kva.example". domain should already be in lower case. Unlike
+// a full IDNA implementation, it does not otherwise map or validate the
+// characters in domain.
+func domainToASCII(domain string) (string, error) {
+	if isASCII(domain) {
+		return domain, nil
+	}
+	labels := strings.Split(domain, ".")
+	for i, label := range labels {
+		if isASCII(label) {
+			continue
+		}
+		encoded, err := punycodeEncode(label)
+		if err != nil {
+			return "", err
+		}
+		labels[i] = "xn--" + encoded
+	}
+	return strings.Join(labels, "."), nil
+}
+
+// Parameters for punycode, as defined in RFC 3492.
+const (
+	punycodeBase        = 36
+	punycodeTMin        = 1
+	punycodeTMax        = 26
+	punycodeSkew        = 38
+	punycodeDamp        = 700
+	punycodeInitialBias = 72
+	punycodeInitialN    = 128
+)
+
+// punycodeEncode encodes label using the punycode algorithm from RFC 3492.
+func punycodeEncode(label string) (string, error) {
+	runes := []rune(label)
+	output := []byte{}
+	for _, r := range runes {
+		if r < utf8.RuneSelf {
+			output = append(output, byte(r))
+		}
+	}
+	basicCount := len(output)
+	handled := basicCount
+	if basicCount > 0 {
+		output = append(output, '-')
+	}
+	n, delta, bias := rune(punycodeInitialN), 0, punycodeInitialBias
+	for handled < len(runes) {
+		// find the smallest code point which has not been handled yet
+		m := rune(utf8.MaxRune)
+		for _, r := range runes {
+			if r >= n && r < m {
+				m = r
+			}
+		}
+		if int(m-n) > (1<<30)/(handled+1) {
+			return "", errors.New("forms: punycode overflow")
+		}
+		delta += int(m-n) * (handled + 1)
+		n = m
+		for _, r := range runes {
+			if r < n {
+				delta++
+			}
+			if r != n {
+				continue
+			}
+			q := delta
+			for k := punycodeBase; ; k += punycodeBase {
+				t := k - bias
+				if t < punycodeTMin {
+					t = punycodeTMin
+				} else if t > punycodeTMax {
+					t = punycodeTMax
+				}
+				if q < t {
+					break
+				}
+				output = append(output, punycodeDigit(t+(q-t)%(punycodeBase-t)))
+				q = (q - t) / (punycodeBase - t)
+			}
+			output = append(output, punycodeDigit(q))
+			bias = punycodeAdapt(delta, handled+1, handled == basicCount)
+			delta = 0
+			handled++
+		}
+		delta++
+		n++
+	}
+	return string(output), nil
+}
+
+// punycodeDigit returns the character which represents d, which must be
+// less than punycodeBase.
+func punycodeDigit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+	return byte('0' + d - 26)
+}
+
+// punycodeAdapt is the bias adaptation function from RFC 3492.
+func punycodeAdapt(delta int, numPoints int, first bool) int {
+	if first {
+		delta /= punycodeDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / numPoints
+	k := 0
+	for delta > ((punycodeBase-punycodeTMin)*punycodeTMax)/2 {
+		delta /= punycodeBase - punycodeTMin
+		k += punycodeBase
+	}
+	return k + (punycodeBase-punycodeTMin+1)*delta/(delta+punycodeSkew)
+}
@@ -0,0 +1,165 @@
+// Copyright 2015 Alex Browne.  All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package forms
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEmail(t *testing.T) {
+	valid := []string{
+		"abc@example.com",
+		"first.last+tag@sub.example.co.uk",
+		"\"john smith\"@example.com",
+		"UPPER@EXAMPLE.COM",
+		strings.Repeat("a", 64) + "@example.com",
+	}
+	invalid := []string{
+		"",
+		"abc.com",
+		"abc@localhost",
+		"abc@-example.com",
+		"abc@[192.168.0.1]",
+		"a..b@example.com",
+		"Bob <bob@example.com>",
+		"<bob@example.com>",
+		"üser@example.com",
+		"user@bücher.example",
+		strings.Repeat("a", 65) + "@example.com",
+		"a@" + strings.Repeat("b", 63) + "." + strings.Repeat("c", 63) + "." + strings.Repeat("d", 63) + "." + strings.Repeat("e", 60) + ".com",
+	}
+	data := newData()
+	for _, value := range valid {
+		data.Set("email", value)
+		val := data.Validator()
+		val.Email("email", nil)
+		if val.HasErrors() {
+			t.Errorf("Expected %q to be valid but got errors: %v", value, val.Messages())
+		}
+	}
+	for _, value := range invalid {
+		data.Set("email", value)
+		val := data.Validator()
+		val.Email("email", nil)
+		if !reflect.DeepEqual(val.Codes(), []string{"email"}) {
+			t.Errorf("Expected %q to be invalid but got codes %v.", value, val.Codes())
+		}
+	}
+}
+
+func TestEmailOptions(t *testing.T) {
+	data := newData()
+	data.Add("unicode", "üser@bücher.example")
+	data.Add("named", "Bob <bob@example.com>")
+	data.Add("disposable", "bob@mail.Mailinator.com")
+	data.Add("allowed", "bob@example.com")
+	opts := &EmailOptions{
+		AllowUnicode:     true,
+		AllowDisplayName: true,
+		Blocklist:        NewDomainSet("mailinator.com", "guerrillamail.com"),
+	}
+	val := data.Validator()
+	val.Email("unicode", opts)
+	val.Email("named", opts)
+	val.Email("allowed", opts)
+	if val.HasErrors() {
+		t.Errorf("Expected no errors but got errors: %v", val.Messages())
+	}
+
+	val.Email("disposable", opts)
+	if !reflect.DeepEqual(val.Codes(), []string{"email_blocked"}) {
+		t.Errorf("Expected code email_blocked but got %v.", val.Codes())
+	}
+	expected := "disposable cannot be an address at mail.mailinator.com."
+	if !reflect.DeepEqual(val.Messages(), []string{expected}) {
+		t.Errorf("Expected message %q but got %v.", expected, val.Messages())
+	}
+}
+
+func TestNormalizeEmail(t *testing.T) {
+	table := []struct {
+		address  string
+		stripTag bool
+		expected string
+	}{
+		{"Bob@Example.COM", false, "Bob@example.com"},
+		{"Bob+news@Example.COM", false, "Bob+news@example.com"},
+		{"Bob+news@Example.COM", true, "Bob@example.com"},
+		{"+news@example.com", true, "+news@example.com"},
+		{"Bob Smith <bob@example.com>", false, "bob@example.com"},
+		{"\"bob smith\"@example.com", false, "\"bob smith\"@example.com"},
+		{"user@Bücher.example", false, "user@xn--bcher-kva.example"},
+	}
+	for _, test := range table {
+		got, err := NormalizeEmail(test.address, test.stripTag)
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v", test.address, err)
+		} else if got != test.expected {
+			t.Errorf("NormalizeEmail(%q, %t) was incorrect. Expected %q, but got %q.", test.address, test.stripTag, test.expected, got)
+		}
+	}
+	if _, err := NormalizeEmail("not an email", false); err == nil {
+		t.Error("Expected an error for an invalid address but got none.")
+	}
+}
+
+func TestLoadDomainSet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "forms-domains-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "disposable.txt")
+	contents := "# disposable email domains\n\nMailinator.com\n  trashmail.net.  \n"
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	set, err := LoadDomainSet(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := DomainSet{"mailinator.com": true, "trashmail.net": true}
+	if !reflect.DeepEqual(set, expected) {
+		t.Errorf("Expected %v but got %v.", expected, set)
+	}
+	for domain, blocked := range map[string]bool{
+		"mailinator.com":    true,
+		"eu.mailinator.com": true,
+		"notmailinator.com": false,
+		"trashmail.net.":    true,
+		"example.com":       false,
+		"com":               false,
+	} {
+		if set.Blocked(domain) != blocked {
+			t.Errorf("Blocked(%q) was incorrect. Expected %t.", domain, blocked)
+		}
+	}
+	if _, err := LoadDomainSet(filepath.Join(dir, "missing.txt")); err == nil {
+		t.Error("Expected an error for a missing file but got none.")
+	}
+}
+
+func TestPunycode(t *testing.T) {
+	table := map[string]string{
+		"bücher":  "bcher-kva",
+		"münchen": "mnchen-3ya",
+		"例え":      "r8jz45g",
+		"ü":       "tda",
+		"español": "espaol-zwa",
+	}
+	for label, expected := range table {
+		got, err := punycodeEncode(label)
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v", label, err)
+		} else if got != expected {
+			t.Errorf("punycodeEncode(%q) was incorrect. Expected %q, but got %q.", label, expected, got)
+		}
+	}
+}
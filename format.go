@@ -0,0 +1,353 @@
+// Copyright 2015 Alex Browne.  All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package forms
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// The validation methods in this file check that a value has a particular
+// format. Unlike most validation methods, the errors they add have a code
+// (e.g. "url"), which is available from Validator.Codes. As with Match, an
+// empty or missing value does not have the expected format, so optional
+// fields should be checked with Validator.When.
+
+// addFormatError adds an error with code and a message saying that field
+// must be a valid description.
+func (v *Validator) addFormatError(field string, code string, description string) *ValidationResult {
+	msg := fmt.Sprintf("%s must be a valid %s.", field, description)
+	return v.AddError(field, msg).Code(code)
+}
+
+// URL will add an error to the Validator if the first element of
+// data.Values[field] is not an absolute URL with a host, or if its scheme
+// is not one of schemes. If no schemes are given, "http" and "https" are
+// allowed. Schemes are compared case-insensitively.
+func (v *Validator) URL(field string, schemes ...string) *ValidationResult {
+	if len(schemes) == 0 {
+		schemes = []string{"http", "https"}
+	}
+	u, err := url.Parse(v.data.Get(field))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return v.addFormatError(field, "url", "URL")
+	}
+	for _, scheme := range schemes {
+		if strings.EqualFold(u.Scheme, scheme) {
+			return validationOk
+		}
+	}
+	msg := fmt.Sprintf("%s must be a URL starting with %s.", field, humanList(schemes, "or"))
+	return v.AddError(field, msg).Code("url_scheme")
+}
+
+// Hostname will add an error to the Validator if the first element of
+// data.Values[field] is not a valid hostname as defined by RFC 1123, e.g.
+// "example.com". A single trailing dot is allowed.
+func (v *Validator) Hostname(field string) *ValidationResult {
+	if !isHostname(v.data.Get(field)) {
+		return v.addFormatError(field, "hostname", "hostname")
+	}
+	return validationOk
+}
+
+// isHostname returns true iff str is a valid hostname as defined by
+// RFC 1123.
+func isHostname(str string) bool {
+	str = strings.TrimSuffix(str, ".")
+	if len(str) == 0 || len(str) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(str, ".") {
+		if len(label) == 0 || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if !isAlphanumeric(c) && c != '-' {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// isAlphanumeric returns true iff c is an ASCII letter or digit.
+func isAlphanumeric(c rune) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// IP will add an error to the Validator if the first element of
+// data.Values[field] is not an IPv4 or IPv6 address.
+func (v *Validator) IP(field string) *ValidationResult {
+	if _, err := netip.ParseAddr(v.data.Get(field)); err != nil {
+		return v.addFormatError(field, "ip", "IP address")
+	}
+	return validationOk
+}
+
+// IPv4 will add an error to the Validator if the first element of
+// data.Values[field] is not an IPv4 address in dotted decimal form, e.g.
+// "192.168.0.1".
+func (v *Validator) IPv4(field string) *ValidationResult {
+	if addr, err := netip.ParseAddr(v.data.Get(field)); err != nil || !addr.Is4() {
+		return v.addFormatError(field, "ipv4", "IPv4 address")
+	}
+	return validationOk
+}
+
+// IPv6 will add an error to the Validator if the first element of
+// data.Values[field] is not an IPv6 address, e.g. "2001:db8::1". This
+// includes IPv4-mapped IPv6 addresses such as "::ffff:192.168.0.1".
+func (v *Validator) IPv6(field string) *ValidationResult {
+	if addr, err := netip.ParseAddr(v.data.Get(field)); err != nil || !addr.Is6() {
+		return v.addFormatError(field, "ipv6", "IPv6 address")
+	}
+	return validationOk
+}
+
+// CIDR will add an error to the Validator if the first element of
+// data.Values[field] is not an IP address prefix in CIDR notation, e.g.
+// "192.168.0.0/16" or "2001:db8::/32".
+func (v *Validator) CIDR(field string) *ValidationResult {
+	if _, err := netip.ParsePrefix(v.data.Get(field)); err != nil {
+		return v.addFormatError(field, "cidr", "CIDR block")
+	}
+	return validationOk
+}
+
+// MACAddress will add an error to the Validator if the first element of
+// data.Values[field] is not a MAC address in any of the formats accepted by
+// net.ParseMAC, e.g. "00:00:5e:00:53:01".
+func (v *Validator) MACAddress(field string) *ValidationResult {
+	if _, err := net.ParseMAC(v.data.Get(field)); err != nil {
+		return v.addFormatError(field, "mac", "MAC address")
+	}
+	return validationOk
+}
+
+// UUID will add an error to the Validator if the first element of
+// data.Values[field] is not a UUID in its canonical form, e.g.
+// "f47ac10b-58cc-4372-a567-0e02b2c3d479", or if versions are given and the
+// UUID's version is not one of them. Upper and lower case are both
+// accepted. The nil UUID is accepted if no versions are given.
+func (v *Validator) UUID(field string, versions ...int) *ValidationResult {
+	version, ok := parseUUIDVersion(v.data.Get(field))
+	if !ok {
+		return v.addFormatError(field, "uuid", "UUID")
+	}
+	if len(versions) == 0 {
+		return validationOk
+	}
+	for _, allowed := range versions {
+		if version == allowed {
+			return validationOk
+		}
+	}
+	strs := make([]string, len(versions))
+	for i, allowed := range versions {
+		strs[i] = strconv.Itoa(allowed)
+	}
+	msg := fmt.Sprintf("%s must be a version %s UUID.", field, humanList(strs, "or"))
+	return v.AddError(field, msg).Code("uuid_version")
+}
+
+// parseUUIDVersion returns the version of str, which should be a UUID in
+// its canonical form. ok is false if str is not a UUID. The nil UUID has
+// version 0.
+func parseUUIDVersion(str string) (version int, ok bool) {
+	if len(str) != 36 {
+		return 0, false
+	}
+	for i, c := range str {
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return 0, false
+			}
+		default:
+			if !isHexDigit(c) {
+				return 0, false
+			}
+		}
+	}
+	if str == "00000000-0000-0000-0000-000000000000" {
+		return 0, true
+	}
+	// Only the variant defined by RFC 4122 has versions, and its variant
+	// bits are 10, so the first digit of the fourth group is 8, 9, a or b.
+	if !strings.ContainsRune("89abAB", rune(str[19])) {
+		return 0, false
+	}
+	version64, _ := strconv.ParseInt(str[14:15], 16, 0)
+	return int(version64), true
+}
+
+// isHexDigit returns true iff c is a hexadecimal digit.
+func isHexDigit(c rune) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// crockfordBase32 is the alphabet used by ULIDs.
+const crockfordBase32 = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULID will add an error to the Validator if the first element of
+// data.Values[field] is not a ULID, e.g. "01ARZ3NDEKTSV4RRFFQ69G5FAV".
+// Upper and lower case are both accepted.
+func (v *Validator) ULID(field string) *ValidationResult {
+	str := strings.ToUpper(v.data.Get(field))
+	// the first character can be at most 7 because a ULID is 128 bits
+	// and 26 base 32 characters can hold 130
+	if len(str) != 26 || str[0] > '7' {
+		return v.addFormatError(field, "ulid", "ULID")
+	}
+	for _, c := range str {
+		if !strings.ContainsRune(crockfordBase32, c) {
+			return v.addFormatError(field, "ulid", "ULID")
+		}
+	}
+	return validationOk
+}
+
+// HexColor will add an error to the Validator if the first element of
+// data.Values[field] is not a hexadecimal color as used in CSS, e.g.
+// "#fff", "#ffffff", or with an alpha channel, "#ffff" or "#ffffffff".
+func (v *Validator) HexColor(field string) *ValidationResult {
+	str := v.data.Get(field)
+	if !strings.HasPrefix(str, "#") {
+		return v.addFormatError(field, "hex_color", "hex color")
+	}
+	digits := str[1:]
+	switch len(digits) {
+	case 3, 4, 6, 8:
+	default:
+		return v.addFormatError(field, "hex_color", "hex color")
+	}
+	for _, c := range digits {
+		if !isHexDigit(c) {
+			return v.addFormatError(field, "hex_color", "hex color")
+		}
+	}
+	return validationOk
+}
+
+// Base64 will add an error to the Validator if the first element of
+// data.Values[field] is not encoded with the standard, padded base64
+// encoding defined in RFC 4648.
+func (v *Validator) Base64(field string) *ValidationResult {
+	str := v.data.Get(field)
+	if _, err := base64.StdEncoding.DecodeString(str); err != nil || str == "" {
+		return v.addFormatError(field, "base64", "base64 string")
+	}
+	return validationOk
+}
+
+// Base64URL will add an error to the Validator if the first element of
+// data.Values[field] is not encoded with the URL and filename safe base64
+// encoding defined in RFC 4648. Padding is optional.
+func (v *Validator) Base64URL(field string) *ValidationResult {
+	str := strings.TrimRight(v.data.Get(field), "=")
+	if _, err := base64.RawURLEncoding.DecodeString(str); err != nil || str == "" {
+		return v.addFormatError(field, "base64url", "base64url string")
+	}
+	return validationOk
+}
+
+// SemVer will add an error to the Validator if the first element of
+// data.Values[field] is not a semantic version as defined by Semantic
+// Versioning 2.0.0, e.g. "1.2.3" or "1.0.0-beta.1+build.5". A leading "v"
+// is not allowed.
+func (v *Validator) SemVer(field string) *ValidationResult {
+	if !isSemVer(v.data.Get(field)) {
+		return v.addFormatError(field, "semver", "semantic version")
+	}
+	return validationOk
+}
+
+// isSemVer returns true iff str is a semantic version.
+func isSemVer(str string) bool {
+	if i := strings.Index(str, "+"); i != -1 {
+		if !isSemVerIdentifiers(str[i+1:], false) {
+			return false
+		}
+		str = str[:i]
+	}
+	if i := strings.Index(str, "-"); i != -1 {
+		if !isSemVerIdentifiers(str[i+1:], true) {
+			return false
+		}
+		str = str[:i]
+	}
+	parts := strings.Split(str, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	for _, part := range parts {
+		if !isNumericIdentifier(part) {
+			return false
+		}
+	}
+	return true
+}
+
+// isSemVerIdentifiers returns true iff str is a series of dot separated
+// pre-release or build identifiers. Numeric pre-release identifiers must
+// not have leading zeros.
+func isSemVerIdentifiers(str string, preRelease bool) bool {
+	for _, ident := range strings.Split(str, ".") {
+		if ident == "" {
+			return false
+		}
+		numeric := true
+		for _, c := range ident {
+			if !isAlphanumeric(c) && c != '-' {
+				return false
+			}
+			if c < '0' || c > '9' {
+				numeric = false
+			}
+		}
+		if preRelease && numeric && !isNumericIdentifier(ident) {
+			return false
+		}
+	}
+	return true
+}
+
+// isNumericIdentifier returns true iff str is a non-negative integer
+// without leading zeros.
+func isNumericIdentifier(str string) bool {
+	if str == "" || (len(str) > 1 && str[0] == '0') {
+		return false
+	}
+	for _, c := range str {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Slug will add an error to the Validator if the first element of
+// data.Values[field] is not a slug, i.e. lower case letters and digits in
+// words separated by single hyphens, e.g. "my-first-post".
+func (v *Validator) Slug(field string) *ValidationResult {
+	str := v.data.Get(field)
+	for _, word := range strings.Split(str, "-") {
+		if word == "" {
+			return v.addFormatError(field, "slug", "slug")
+		}
+		for _, c := range word {
+			if !(c >= 'a' && c <= 'z') && !(c >= '0' && c <= '9') {
+				return v.addFormatError(field, "slug", "slug")
+			}
+		}
+	}
+	return validationOk
+}
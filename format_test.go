@@ -0,0 +1,191 @@
+// Copyright 2015 Alex Browne.  All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package forms
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// formatTest is a test case for a format validation method. valid and
+// invalid are values which should pass and fail the validation.
+type formatTest struct {
+	name     string
+	validate func(val *Validator, field string) *ValidationResult
+	code     string
+	valid    []string
+	invalid  []string
+}
+
+// runFormatTests checks that each of the valid values in table passes its
+// validation and each of the invalid values fails with the expected code.
+func runFormatTests(t *testing.T, table []formatTest) {
+	for _, test := range table {
+		data := newData()
+		for _, value := range test.valid {
+			data.Set("field", value)
+			val := data.Validator()
+			test.validate(val, "field")
+			if val.HasErrors() {
+				t.Errorf("%s: Expected %q to be valid but got errors: %v", test.name, value, val.Messages())
+			}
+		}
+		for _, value := range test.invalid {
+			data.Set("field", value)
+			val := data.Validator()
+			test.validate(val, "field")
+			if !reflect.DeepEqual(val.Codes(), []string{test.code}) {
+				t.Errorf("%s: Expected %q to be invalid with code %s but got codes %v.", test.name, value, test.code, val.Codes())
+			}
+		}
+	}
+}
+
+func TestFormats(t *testing.T) {
+	table := []formatTest{
+		{
+			name:     "URL",
+			validate: func(val *Validator, field string) *ValidationResult { return val.URL(field) },
+			code:     "url",
+			valid:    []string{"http://example.com", "HTTPS://example.com:8080/a?b=c#d"},
+			invalid:  []string{"", "example.com", "/path", "http://", "http://exa mple.com"},
+		},
+		{
+			name:     "Hostname",
+			validate: (*Validator).Hostname,
+			code:     "hostname",
+			valid:    []string{"localhost", "example.com", "example.com.", "xn--bcher-kva.example", "a-1.b2"},
+			invalid:  []string{"", "-example.com", "example-.com", "exa_mple.com", "example..com", ".", strings.Repeat("a", 64) + ".com"},
+		},
+		{
+			name:     "IP",
+			validate: (*Validator).IP,
+			code:     "ip",
+			valid:    []string{"192.168.0.1", "2001:db8::1"},
+			invalid:  []string{"", "192.168.0", "256.0.0.1", "example.com"},
+		},
+		{
+			name:     "IPv4",
+			validate: (*Validator).IPv4,
+			code:     "ipv4",
+			valid:    []string{"0.0.0.0", "192.168.0.1"},
+			invalid:  []string{"2001:db8::1", "::ffff:192.168.0.1", "192.168.00.1"},
+		},
+		{
+			name:     "IPv6",
+			validate: (*Validator).IPv6,
+			code:     "ipv6",
+			valid:    []string{"::1", "2001:db8::1", "::ffff:192.168.0.1", "fe80::1%eth0"},
+			invalid:  []string{"192.168.0.1", "2001:db8:::1", "[::1]"},
+		},
+		{
+			name:     "CIDR",
+			validate: (*Validator).CIDR,
+			code:     "cidr",
+			valid:    []string{"192.168.0.0/16", "10.0.0.1/32", "2001:db8::/32"},
+			invalid:  []string{"192.168.0.0", "192.168.0.0/33", "2001:db8::/129"},
+		},
+		{
+			name:     "MACAddress",
+			validate: (*Validator).MACAddress,
+			code:     "mac",
+			valid:    []string{"00:00:5e:00:53:01", "00-00-5E-00-53-01", "0000.5e00.5301"},
+			invalid:  []string{"", "00:00:5e:00:53", "00:00:5e:00:53:zz"},
+		},
+		{
+			name:     "UUID",
+			validate: func(val *Validator, field string) *ValidationResult { return val.UUID(field) },
+			code:     "uuid",
+			valid:    []string{"f47ac10b-58cc-4372-a567-0e02b2c3d479", "F47AC10B-58CC-1372-8567-0E02B2C3D479", "00000000-0000-0000-0000-000000000000"},
+			invalid:  []string{"", "f47ac10b58cc4372a5670e02b2c3d479", "f47ac10b-58cc-4372-c567-0e02b2c3d479", "g47ac10b-58cc-4372-a567-0e02b2c3d479", "{f47ac10b-58cc-4372-a567-0e02b2c3d47}"},
+		},
+		{
+			name:     "ULID",
+			validate: (*Validator).ULID,
+			code:     "ulid",
+			valid:    []string{"01ARZ3NDEKTSV4RRFFQ69G5FAV", "01arz3ndektsv4rrffq69g5fav", "7ZZZZZZZZZZZZZZZZZZZZZZZZZ"},
+			invalid:  []string{"", "01ARZ3NDEKTSV4RRFFQ69G5FA", "8ZZZZZZZZZZZZZZZZZZZZZZZZZ", "01ARZ3NDEKTSV4RRFFQ69G5FAU"},
+		},
+		{
+			name:     "HexColor",
+			validate: (*Validator).HexColor,
+			code:     "hex_color",
+			valid:    []string{"#fff", "#FFFA", "#1a2b3c", "#1a2b3c80"},
+			invalid:  []string{"", "fff", "#ff", "#fffff", "#ggg"},
+		},
+		{
+			name:     "Base64",
+			validate: (*Validator).Base64,
+			code:     "base64",
+			valid:    []string{"SGVsbG8h", "SGVsbG8=", "+/+/"},
+			invalid:  []string{"", "SGVsbG8", "SGVsbG8h!", "-_-_"},
+		},
+		{
+			name:     "Base64URL",
+			validate: (*Validator).Base64URL,
+			code:     "base64url",
+			valid:    []string{"SGVsbG8h", "SGVsbG8", "SGVsbG8=", "-_-_"},
+			invalid:  []string{"", "+/+/", "SGVsbG8h!"},
+		},
+		{
+			name:     "SemVer",
+			validate: (*Validator).SemVer,
+			code:     "semver",
+			valid:    []string{"0.0.0", "1.2.3", "10.20.30", "1.0.0-alpha.1", "1.0.0-0a.1", "1.0.0+build.01", "1.0.0-rc-1+20150321"},
+			invalid:  []string{"", "1.2", "v1.2.3", "01.2.3", "1.2.3-01", "1.2.3-", "1.2.3+", "1.2.3-alpha..1", "1.2.3-al_pha"},
+		},
+		{
+			name:     "Slug",
+			validate: (*Validator).Slug,
+			code:     "slug",
+			valid:    []string{"post", "my-first-post", "2015-03-21"},
+			invalid:  []string{"", "-post", "post-", "my--post", "My-Post", "my_post", "my post"},
+		},
+	}
+	runFormatTests(t, table)
+}
+
+func TestFormatMessages(t *testing.T) {
+	data := newData()
+	data.Add("website", "ftp://example.com")
+	data.Add("id", "f47ac10b-58cc-1372-a567-0e02b2c3d479")
+	data.Add("color", "red")
+	val := data.Validator()
+	val.URL("website", "ftp", "sftp")
+	val.UUID("id", 1)
+	if val.HasErrors() {
+		t.Errorf("Expected no errors but got errors: %v", val.Messages())
+	}
+
+	val.URL("website")
+	val.UUID("id", 4, 7)
+	val.HexColor("color")
+	val.Require("missing")
+	expectedMessages := []string{
+		"website must be a URL starting with http or https.",
+		"id must be a version 4 or 7 UUID.",
+		"color must be a valid hex color.",
+		"missing is required.",
+	}
+	if !reflect.DeepEqual(val.Messages(), expectedMessages) {
+		t.Errorf("Expected messages %v but got %v.", expectedMessages, val.Messages())
+	}
+	expectedCodes := []string{"url_scheme", "uuid_version", "hex_color", ""}
+	if !reflect.DeepEqual(val.Codes(), expectedCodes) {
+		t.Errorf("Expected codes %v but got %v.", expectedCodes, val.Codes())
+	}
+}
+
+func TestCustomCode(t *testing.T) {
+	data := newData()
+	val := data.Validator()
+	val.Require("name").Code("name_required")
+	val.Slug("slug").Code("bad_slug")
+	expected := []string{"name_required", "bad_slug"}
+	if !reflect.DeepEqual(val.Codes(), expected) {
+		t.Errorf("Expected codes %v but got %v.", expected, val.Codes())
+	}
+}
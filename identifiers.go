@@ -0,0 +1,216 @@
+// Copyright 2015 Alex Browne.  All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package forms
+
+import (
+	"strings"
+)
+
+// The validation methods in this file check numbers which are commonly
+// entered with spaces or dashes for readability, such as card numbers and
+// IBANs. The spaces and dashes are removed before checking, so users do not
+// have to remove them. Like the methods in format.go, the errors they add
+// have a code.
+
+// stripSeparators returns str without any spaces or dashes.
+func stripSeparators(str string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, str)
+}
+
+// isDigits returns true iff str is not empty and contains only ASCII
+// digits.
+func isDigits(str string) bool {
+	if str == "" {
+		return false
+	}
+	for _, c := range str {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// luhnValid returns true iff digits, which must contain only ASCII digits,
+// has a valid Luhn (mod 10) check digit.
+func luhnValid(digits string) bool {
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// Luhn will add an error to the Validator if the first element of
+// data.Values[field], ignoring spaces and dashes, is not a number of at
+// least two digits with a valid Luhn check digit. Use CardNumber for
+// payment card numbers.
+func (v *Validator) Luhn(field string) *ValidationResult {
+	digits := stripSeparators(v.data.Get(field))
+	if len(digits) < 2 || !isDigits(digits) || !luhnValid(digits) {
+		return v.addFormatError(field, "luhn", "number")
+	}
+	return validationOk
+}
+
+// CardNumber will add an error to the Validator if the first element of
+// data.Values[field], ignoring spaces and dashes, is not a payment card
+// number, i.e. 12 to 19 digits with a valid Luhn check digit, e.g.
+// "4111 1111 1111 1111". It does not check whether the card exists.
+func (v *Validator) CardNumber(field string) *ValidationResult {
+	digits := stripSeparators(v.data.Get(field))
+	if len(digits) < 12 || len(digits) > 19 || !isDigits(digits) || !luhnValid(digits) {
+		return v.addFormatError(field, "card_number", "card number")
+	}
+	return validationOk
+}
+
+// ibanLengths holds the length of an IBAN in each country which uses them,
+// from the IBAN registry.
+var ibanLengths = map[string]int{
+	"AD": 24, "AE": 23, "AL": 28, "AT": 20, "AZ": 28, "BA": 20, "BE": 16,
+	"BG": 22, "BH": 22, "BI": 27, "BR": 29, "BY": 28, "CH": 21, "CR": 22,
+	"CY": 28, "CZ": 24, "DE": 22, "DJ": 27, "DK": 18, "DO": 28, "EE": 20,
+	"EG": 29, "ES": 24, "FI": 18, "FK": 18, "FO": 18, "FR": 27, "GB": 22,
+	"GE": 22, "GI": 23, "GL": 18, "GR": 27, "GT": 28, "HR": 21, "HU": 28,
+	"IE": 22, "IL": 23, "IQ": 23, "IS": 26, "IT": 27, "JO": 30, "KW": 30,
+	"KZ": 20, "LB": 28, "LC": 32, "LI": 21, "LT": 20, "LU": 20, "LV": 21,
+	"LY": 25, "MC": 27, "MD": 24, "ME": 22, "MK": 19, "MN": 20, "MR": 27,
+	"MT": 31, "MU": 30, "NI": 28, "NL": 18, "NO": 15, "OM": 23, "PK": 24,
+	"PL": 28, "PS": 29, "PT": 25, "QA": 29, "RO": 24, "RS": 22, "RU": 33,
+	"SA": 24, "SC": 31, "SD": 18, "SE": 24, "SI": 19, "SK": 24, "SM": 27,
+	"SO": 23, "ST": 25, "SV": 28, "TL": 23, "TN": 24, "TR": 26, "UA": 29,
+	"VA": 22, "VG": 24, "XK": 20, "YE": 30,
+}
+
+// IBAN will add an error to the Validator if the first element of
+// data.Values[field], ignoring spaces, dashes and case, is not an
+// International Bank Account Number with the correct length for its
+// country and valid check digits, e.g. "GB82 WEST 1234 5698 7654 32".
+func (v *Validator) IBAN(field string) *ValidationResult {
+	if !isIBAN(strings.ToUpper(stripSeparators(v.data.Get(field)))) {
+		return v.addFormatError(field, "iban", "IBAN")
+	}
+	return validationOk
+}
+
+// isIBAN returns true iff str, which should be in upper case, is a valid
+// IBAN.
+func isIBAN(str string) bool {
+	if len(str) < 4 || len(str) != ibanLengths[str[:2]] || !isDigits(str[2:4]) {
+		return false
+	}
+	// Move the country code and check digits to the end, replace each
+	// letter with two digits (A = 10, B = 11, ...) and check that the
+	// resulting number mod 97 is 1. The number is too big for an int, so
+	// compute the remainder one digit at a time.
+	remainder := 0
+	for _, c := range str[4:] + str[:4] {
+		switch {
+		case c >= '0' && c <= '9':
+			remainder = (remainder*10 + int(c-'0')) % 97
+		case c >= 'A' && c <= 'Z':
+			remainder = (remainder*100 + int(c-'A') + 10) % 97
+		default:
+			return false
+		}
+	}
+	return remainder == 1
+}
+
+// ISBN will add an error to the Validator if the first element of
+// data.Values[field], ignoring spaces and dashes, is neither an ISBN-10
+// nor an ISBN-13 with a valid check digit.
+func (v *Validator) ISBN(field string) *ValidationResult {
+	str := stripSeparators(v.data.Get(field))
+	if !isISBN10(str) && !isISBN13(str) {
+		return v.addFormatError(field, "isbn", "ISBN")
+	}
+	return validationOk
+}
+
+// ISBN10 will add an error to the Validator if the first element of
+// data.Values[field], ignoring spaces and dashes, is not an ISBN-10 with a
+// valid check digit, e.g. "0-306-40615-2". The check digit may be an "X".
+func (v *Validator) ISBN10(field string) *ValidationResult {
+	if !isISBN10(stripSeparators(v.data.Get(field))) {
+		return v.addFormatError(field, "isbn", "ISBN-10")
+	}
+	return validationOk
+}
+
+// ISBN13 will add an error to the Validator if the first element of
+// data.Values[field], ignoring spaces and dashes, is not an ISBN-13 with a
+// valid check digit, e.g. "978-0-306-40615-7".
+func (v *Validator) ISBN13(field string) *ValidationResult {
+	if !isISBN13(stripSeparators(v.data.Get(field))) {
+		return v.addFormatError(field, "isbn", "ISBN-13")
+	}
+	return validationOk
+}
+
+// isISBN10 returns true iff str is an ISBN-10 without separators.
+func isISBN10(str string) bool {
+	if len(str) != 10 || !isDigits(str[:9]) {
+		return false
+	}
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += (10 - i) * int(str[i]-'0')
+	}
+	switch check := str[9]; {
+	case check == 'X' || check == 'x':
+		sum += 10
+	case check >= '0' && check <= '9':
+		sum += int(check - '0')
+	default:
+		return false
+	}
+	return sum%11 == 0
+}
+
+// isISBN13 returns true iff str is an ISBN-13 without separators.
+func isISBN13(str string) bool {
+	if len(str) != 13 || !isDigits(str) || !(strings.HasPrefix(str, "978") || strings.HasPrefix(str, "979")) {
+		return false
+	}
+	sum := 0
+	for i := 0; i < 13; i++ {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += weight * int(str[i]-'0')
+	}
+	return sum%10 == 0
+}
+
+// E164 will add an error to the Validator if the first element of
+// data.Values[field], ignoring spaces and dashes, is not a phone number in
+// the international E.164 format, i.e. a "+" followed by a country code
+// and subscriber number of at most 15 digits in total, e.g.
+// "+1 415-555-2671". It does not check whether the number is assigned.
+func (v *Validator) E164(field string) *ValidationResult {
+	str := stripSeparators(v.data.Get(field))
+	digits := strings.TrimPrefix(str, "+")
+	if len(digits) == len(str) || len(digits) > 15 || !isDigits(digits) || digits[0] == '0' {
+		return v.addFormatError(field, "e164", "phone number")
+	}
+	return validationOk
+}
@@ -0,0 +1,64 @@
+// Copyright 2015 Alex Browne.  All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package forms
+
+import (
+	"testing"
+)
+
+func TestIdentifiers(t *testing.T) {
+	table := []formatTest{
+		{
+			name:     "Luhn",
+			validate: (*Validator).Luhn,
+			code:     "luhn",
+			valid:    []string{"79927398713", "0 0", "18"},
+			invalid:  []string{"", "0", "79927398710", "7992739871a"},
+		},
+		{
+			name:     "CardNumber",
+			validate: (*Validator).CardNumber,
+			code:     "card_number",
+			valid:    []string{"4111111111111111", "4111 1111 1111 1111", "5500-0000-0000-0004", "378282246310005"},
+			invalid:  []string{"", "4111 1111 1111 1112", "79927398713", "4111.1111.1111.1111", "41111111111111111111"},
+		},
+		{
+			name:     "IBAN",
+			validate: (*Validator).IBAN,
+			code:     "iban",
+			valid:    []string{"GB82 WEST 1234 5698 7654 32", "gb82west12345698765432", "DE89370400440532013000", "NO9386011117947"},
+			invalid:  []string{"", "GB82 WEST 1234 5698 7654 33", "GB82 WEST 1234 5698 7654", "XX82WEST12345698765432", "GB82 WEST 1234 5698 7654 3!"},
+		},
+		{
+			name:     "ISBN",
+			validate: (*Validator).ISBN,
+			code:     "isbn",
+			valid:    []string{"0-306-40615-2", "080442957X", "0 8044 2957 x", "978-0-306-40615-7", "9791090636071"},
+			invalid:  []string{"", "0-306-40615-3", "978-0-306-40615-8", "977-0-306-40615-7", "X804429570"},
+		},
+		{
+			name:     "ISBN10",
+			validate: (*Validator).ISBN10,
+			code:     "isbn",
+			valid:    []string{"0-306-40615-2"},
+			invalid:  []string{"978-0-306-40615-7"},
+		},
+		{
+			name:     "ISBN13",
+			validate: (*Validator).ISBN13,
+			code:     "isbn",
+			valid:    []string{"978-0-306-40615-7"},
+			invalid:  []string{"0-306-40615-2"},
+		},
+		{
+			name:     "E164",
+			validate: (*Validator).E164,
+			code:     "e164",
+			valid:    []string{"+14155552671", "+1 415-555-2671", "+44 20 7183 8750", "+123456789012345"},
+			invalid:  []string{"", "14155552671", "+", "+0123", "+1234567890123456", "+1 (415) 555-2671", "++14155552671"},
+		},
+	}
+	runFormatTests(t, table)
+}
@@ -0,0 +1,131 @@
+// Copyright 2015 Alex Browne.  All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package forms
+
+import (
+	"fmt"
+	"image"
+	// Register the decoders for the image formats supported by the
+	// image validators.
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"strings"
+)
+
+// GetImageConfig decodes the header of the image file associated with key
+// and returns its dimensions and format name (e.g. "png", "jpeg", or "gif").
+// Only the header of the image is read, not the entire image. If there is
+// no file associated with key, it returns a zero image.Config and an empty
+// format (not an error). It returns an error if the file could not be read
+// or is not in a supported image format.
+func (d Data) GetImageConfig(key string) (image.Config, string, error) {
+	header, found := d.Files[key]
+	if !found {
+		return image.Config{}, "", nil
+	}
+	file, err := header.Open()
+	if err != nil {
+		return image.Config{}, "", err
+	}
+	defer file.Close()
+	return image.DecodeConfig(file)
+}
+
+// imageConfig returns the image config for the file identified by field.
+// If the image could not be decoded, it adds an error to the Validator and
+// returns the corresponding ValidationResult.
+func (v *Validator) imageConfig(field string) (image.Config, string, *ValidationResult) {
+	config, format, err := v.data.GetImageConfig(field)
+	if err != nil {
+		return config, format, v.addImageInvalidError(field)
+	}
+	return config, format, nil
+}
+
+func (v *Validator) addImageInvalidError(field string) *ValidationResult {
+	msg := fmt.Sprintf("%s must be a valid image.", field)
+	return v.AddError(field, msg)
+}
+
+// MinDimensions will add an error to the Validator if the image file
+// identified by field is narrower than width pixels or shorter than height
+// pixels, or if it is not a valid image. If the file does not exist, it
+// does not add an error to the Validator.
+func (v *Validator) MinDimensions(field string, width int, height int) *ValidationResult {
+	if !v.data.FileExists(field) {
+		return validationOk
+	}
+	config, _, result := v.imageConfig(field)
+	if result != nil {
+		return result
+	}
+	if config.Width < width || config.Height < height {
+		msg := fmt.Sprintf("%s must be at least %dx%d pixels.", field, width, height)
+		return v.AddError(field, msg)
+	}
+	return validationOk
+}
+
+// MaxDimensions will add an error to the Validator if the image file
+// identified by field is wider than width pixels or taller than height
+// pixels, or if it is not a valid image. If the file does not exist, it
+// does not add an error to the Validator.
+func (v *Validator) MaxDimensions(field string, width int, height int) *ValidationResult {
+	if !v.data.FileExists(field) {
+		return validationOk
+	}
+	config, _, result := v.imageConfig(field)
+	if result != nil {
+		return result
+	}
+	if config.Width > width || config.Height > height {
+		msg := fmt.Sprintf("%s cannot be larger than %dx%d pixels.", field, width, height)
+		return v.AddError(field, msg)
+	}
+	return validationOk
+}
+
+// ExactAspectRatio will add an error to the Validator if the ratio of the
+// width to the height of the image file identified by field is not exactly
+// width:height (e.g. 16:9), or if it is not a valid image. If the file does
+// not exist, it does not add an error to the Validator.
+func (v *Validator) ExactAspectRatio(field string, width int, height int) *ValidationResult {
+	if !v.data.FileExists(field) {
+		return validationOk
+	}
+	config, _, result := v.imageConfig(field)
+	if result != nil {
+		return result
+	}
+	if int64(config.Width)*int64(height) != int64(config.Height)*int64(width) {
+		msg := fmt.Sprintf("%s must have an aspect ratio of %d:%d.", field, width, height)
+		return v.AddError(field, msg)
+	}
+	return validationOk
+}
+
+// AllowedImageFormats will add an error to the Validator if the format of
+// the image file identified by field is not in formats, or if it is not a
+// valid image. formats should be one or more format names as reported by
+// the image package, i.e. "png", "jpeg", or "gif". The format is determined
+// by decoding the image header, not from the file extension. If the file
+// does not exist, it does not add an error to the Validator.
+func (v *Validator) AllowedImageFormats(field string, formats ...string) *ValidationResult {
+	if !v.data.FileExists(field) {
+		return validationOk
+	}
+	_, format, result := v.imageConfig(field)
+	if result != nil {
+		return result
+	}
+	for _, allowed := range formats {
+		if strings.EqualFold(allowed, format) {
+			return validationOk
+		}
+	}
+	msg := fmt.Sprintf("The image format %s is not allowed. Allowed formats include: %s", format, humanList(formats, "and"))
+	return v.AddError(field, msg)
+}
@@ -0,0 +1,120 @@
+// Copyright 2015 Alex Browne.  All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package forms
+
+import (
+	"bytes"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func createTestImageData(t *testing.T) *Data {
+	data := newData()
+	img := image.NewRGBA(image.Rect(0, 0, 160, 90))
+	encoders := []struct {
+		key    string
+		encode func(buf *bytes.Buffer) error
+	}{
+		{"png", func(buf *bytes.Buffer) error { return png.Encode(buf, img) }},
+		{"jpeg", func(buf *bytes.Buffer) error { return jpeg.Encode(buf, img, nil) }},
+		{"gif", func(buf *bytes.Buffer) error { return gif.Encode(buf, img, nil) }},
+	}
+	for _, encoder := range encoders {
+		buf := bytes.NewBuffer([]byte{})
+		if err := encoder.encode(buf); err != nil {
+			t.Fatal(err)
+		}
+		fileHeader, err := createTestFileHeader("image."+encoder.key, buf.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		data.AddFile(encoder.key, fileHeader)
+	}
+	notImage, err := createTestFileHeader("image.png", []byte("Hello!"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data.AddFile("notImage", notImage)
+	return data
+}
+
+func TestGetImageConfig(t *testing.T) {
+	data := createTestImageData(t)
+	for _, key := range []string{"png", "jpeg", "gif"} {
+		config, format, err := data.GetImageConfig(key)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if format != key {
+			t.Errorf("Expected format to be %s but got %s.", key, format)
+		}
+		if config.Width != 160 || config.Height != 90 {
+			t.Errorf("Expected %s dimensions to be 160x90 but got %dx%d.", key, config.Width, config.Height)
+		}
+	}
+	if _, _, err := data.GetImageConfig("notImage"); err == nil {
+		t.Error("Expected an error for a file which is not an image but got none.")
+	}
+}
+
+func TestImageDimensions(t *testing.T) {
+	data := createTestImageData(t)
+	val := data.Validator()
+	val.MinDimensions("png", 160, 90)
+	val.MaxDimensions("jpeg", 160, 90)
+	val.MinDimensions("missing", 1000, 1000)
+	if val.HasErrors() {
+		t.Errorf("Expected no errors but got errors: %v", val.Messages())
+	}
+
+	val.MinDimensions("png", 161, 90)
+	val.MaxDimensions("gif", 100, 100)
+	val.MinDimensions("notImage", 1, 1)
+	if len(val.Messages()) != 3 {
+		t.Fatalf("Expected 3 validation errors but got %d.", len(val.Messages()))
+	}
+	if msg := val.Messages()[2]; !strings.Contains(msg, "valid image") {
+		t.Errorf("Expected message to say the image was invalid but got: %s", msg)
+	}
+}
+
+func TestExactAspectRatio(t *testing.T) {
+	data := createTestImageData(t)
+	val := data.Validator()
+	val.ExactAspectRatio("png", 16, 9)
+	val.ExactAspectRatio("gif", 32, 18)
+	if val.HasErrors() {
+		t.Errorf("Expected no errors but got errors: %v", val.Messages())
+	}
+
+	val.ExactAspectRatio("jpeg", 4, 3)
+	if len(val.Messages()) != 1 {
+		t.Errorf("Expected 1 validation error but got %d.", len(val.Messages()))
+	}
+}
+
+func TestAllowedImageFormats(t *testing.T) {
+	data := createTestImageData(t)
+	val := data.Validator()
+	val.AllowedImageFormats("png", "png")
+	val.AllowedImageFormats("jpeg", "png", "JPEG")
+	if val.HasErrors() {
+		t.Errorf("Expected no errors but got errors: %v", val.Messages())
+	}
+
+	val.AllowedImageFormats("gif", "png", "jpeg")
+	if len(val.Messages()) != 1 {
+		t.Fatalf("Expected 1 validation error but got %d.", len(val.Messages()))
+	}
+	expected := "The image format gif is not allowed. Allowed formats include: png and jpeg"
+	if msg := val.Messages()[0]; msg != expected {
+		t.Errorf(`Expected message "%s" but got "%s"`, expected, msg)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2015 Alex Browne.  All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package forms
+
+import (
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+)
+
+// partFunc is called by a partObserver for each part of a multipart body.
+// It may read from part. Returning an error stops the observer from
+// looking at any further parts.
+type partFunc func(part *multipart.Part) error
+
+// chainPartFuncs returns a partFunc which calls each of fns in order.
+func chainPartFuncs(fns ...partFunc) partFunc {
+	return func(part *multipart.Part) error {
+		for _, fn := range fns {
+			if err := fn(part); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// partObserver reads a copy of a multipart request body in a separate
+// goroutine, so that information about each part can be collected while
+// the body is parsed as usual by http.Request.ParseMultipartForm.
+type partObserver struct {
+	pipeWriter *io.PipeWriter
+	done       chan struct{}
+}
+
+// teeReadCloser reads from Reader and closes Closer.
+type teeReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// observeMultipart replaces the body of req with one which also sends
+// everything that is read to an observer, which calls fn for each part.
+// close must be called on the returned partObserver once the body has
+// been read. If the request does not have a valid multipart boundary,
+// fn is never called.
+func observeMultipart(req *http.Request, fn partFunc) *partObserver {
+	pipeReader, pipeWriter := io.Pipe()
+	observer := &partObserver{
+		pipeWriter: pipeWriter,
+		done:       make(chan struct{}),
+	}
+	if body := req.Body; body != nil {
+		req.Body = &teeReadCloser{
+			Reader: io.TeeReader(body, pipeWriter),
+			Closer: body,
+		}
+	}
+	_, params, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	boundary := params["boundary"]
+	go func() {
+		defer close(observer.done)
+		if err == nil && boundary != "" {
+			reader := multipart.NewReader(pipeReader, boundary)
+			for {
+				part, err := reader.NextPart()
+				if err != nil {
+					break
+				}
+				if err := fn(part); err != nil {
+					break
+				}
+			}
+		}
+		// Keep reading until the pipe is closed so that reading
+		// the request body is never blocked by the observer.
+		io.Copy(ioutil.Discard, pipeReader)
+	}()
+	return observer
+}
+
+// close signals that the request body has been read and waits for the
+// observer to finish.
+func (o *partObserver) close() {
+	o.pipeWriter.Close()
+	<-o.done
+}
@@ -0,0 +1,238 @@
+// Copyright 2015 Alex Browne.  All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package forms
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// PasswordPolicy describes the requirements checked by
+// Validator.PasswordStrength. The zero value has no requirements.
+type PasswordPolicy struct {
+	// MinLength and MaxLength limit the number of characters in the
+	// password. A MaxLength of 0 means there is no maximum.
+	MinLength int
+	MaxLength int
+	// RequireUpper, RequireLower, RequireDigit and RequireSymbol require
+	// the password to contain at least one character of each class.
+	// Symbols are any characters which are not letters or digits.
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// MinEntropy, if not 0, is the minimum number of bits of entropy the
+	// password must have, as estimated by PasswordEntropy.
+	MinEntropy float64
+	// DisallowFields are the names of other fields, such as "username" or
+	// "email", whose values the password must not contain. The comparison
+	// is case-insensitive, and for values which look like email addresses
+	// the local part is checked too. Values shorter than three characters
+	// are ignored.
+	DisallowFields []string
+	// Blocklist, if not nil, is used to reject passwords which are known
+	// to be common or to have been breached.
+	Blocklist PasswordBlocklist
+}
+
+// DefaultPasswordPolicy is the policy used by PasswordStrength when policy
+// is nil. It follows the NIST guidelines (SP 800-63B), which favor length
+// over character-class requirements.
+var DefaultPasswordPolicy = &PasswordPolicy{
+	MinLength:  8,
+	MaxLength:  64,
+	MinEntropy: 36,
+}
+
+// PasswordStrength will add an error to the Validator if the first element
+// of data.Values[field] does not meet the requirements of policy. If policy
+// is nil, DefaultPasswordPolicy is used. At most one error is added, with
+// the code "password" and a message which lists every requirement which
+// was not met, e.g. "password must be at least 8 characters long and
+// contain a digit."
+func (v *Validator) PasswordStrength(field string, policy *PasswordPolicy) *ValidationResult {
+	if policy == nil {
+		policy = DefaultPasswordPolicy
+	}
+	password := v.data.Get(field)
+	length := utf8.RuneCountInString(password)
+	unmet := []string{}
+	if length < policy.MinLength {
+		unmet = append(unmet, fmt.Sprintf("be at least %d characters long", policy.MinLength))
+	}
+	if policy.MaxLength != 0 && length > policy.MaxLength {
+		unmet = append(unmet, fmt.Sprintf("be at most %d characters long", policy.MaxLength))
+	}
+	classes := passwordClasses(password)
+	if policy.RequireUpper && !classes.upper {
+		unmet = append(unmet, "contain an upper case letter")
+	}
+	if policy.RequireLower && !classes.lower {
+		unmet = append(unmet, "contain a lower case letter")
+	}
+	if policy.RequireDigit && !classes.digit {
+		unmet = append(unmet, "contain a digit")
+	}
+	if policy.RequireSymbol && !classes.symbol {
+		unmet = append(unmet, "contain a symbol")
+	}
+	for _, other := range policy.DisallowFields {
+		if v.passwordContainsField(password, other) {
+			unmet = append(unmet, fmt.Sprintf("not contain your %s", other))
+		}
+	}
+	if policy.Blocklist != nil && password != "" && policy.Blocklist.Blocked(password) {
+		unmet = append(unmet, "not be a commonly used password")
+	} else if policy.MinEntropy != 0 && length >= policy.MinLength && PasswordEntropy(password) < policy.MinEntropy {
+		// only complain about entropy if there is nothing more specific
+		// to say about the length
+		unmet = append(unmet, "be harder to guess")
+	}
+	if len(unmet) == 0 {
+		return validationOk
+	}
+	msg := fmt.Sprintf("%s must %s.", field, humanList(unmet, "and"))
+	return v.AddError(field, msg).Code("password")
+}
+
+// passwordContainsField returns true iff password contains the value of
+// field, ignoring case.
+func (v *Validator) passwordContainsField(password string, field string) bool {
+	lower := strings.ToLower(password)
+	value := strings.ToLower(strings.TrimSpace(v.data.Get(field)))
+	candidates := []string{value}
+	if i := strings.LastIndex(value, "@"); i != -1 {
+		candidates = append(candidates, value[:i])
+	}
+	for _, candidate := range candidates {
+		if utf8.RuneCountInString(candidate) >= 3 && strings.Contains(lower, candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+// characterClasses records which classes of character a password contains.
+type characterClasses struct {
+	upper, lower, digit, symbol, other bool
+}
+
+// passwordClasses returns the classes of character password contains.
+func passwordClasses(password string) characterClasses {
+	classes := characterClasses{}
+	for _, r := range password {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			classes.upper = true
+		case r >= 'a' && r <= 'z':
+			classes.lower = true
+		case r >= '0' && r <= '9':
+			classes.digit = true
+		case r < utf8.RuneSelf:
+			classes.symbol = true
+		case unicode.IsUpper(r):
+			classes.upper, classes.other = true, true
+		case unicode.IsLower(r):
+			classes.lower, classes.other = true, true
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			classes.other = true
+		default:
+			classes.symbol, classes.other = true, true
+		}
+	}
+	return classes
+}
+
+// PasswordEntropy returns a rough estimate of the entropy of password in
+// bits, based on the size of the character classes it uses and its
+// length. Characters which repeat the previous character or continue a
+// sequence such as "abc" or "123" are not counted, so "aaaaaaaa" and
+// "12345678" have little entropy. It is not a substitute for checking
+// passwords against a list of common passwords.
+func PasswordEntropy(password string) float64 {
+	classes := passwordClasses(password)
+	pool := 0
+	if classes.lower {
+		pool += 26
+	}
+	if classes.upper {
+		pool += 26
+	}
+	if classes.digit {
+		pool += 10
+	}
+	if classes.symbol {
+		pool += 33
+	}
+	if classes.other {
+		pool += 100
+	}
+	if pool == 0 {
+		return 0
+	}
+	count := 0
+	prev := rune(-1)
+	for _, r := range password {
+		if r != prev && r != prev+1 && r != prev-1 {
+			count++
+		}
+		prev = r
+	}
+	return float64(count) * math.Log2(float64(pool))
+}
+
+// PasswordBlocklist decides whether a password should be rejected, e.g.
+// because it is commonly used or has appeared in a data breach.
+type PasswordBlocklist interface {
+	// Blocked returns true iff password should be rejected.
+	Blocked(password string) bool
+}
+
+// PasswordSet is a PasswordBlocklist which blocks the passwords it
+// contains, ignoring case. Keys must be in lower case.
+type PasswordSet map[string]bool
+
+// NewPasswordSet returns a PasswordSet containing passwords.
+func NewPasswordSet(passwords ...string) PasswordSet {
+	set := PasswordSet{}
+	for _, password := range passwords {
+		set[strings.ToLower(password)] = true
+	}
+	return set
+}
+
+// LoadPasswordSet reads a PasswordSet from the file at path, which should
+// contain one password per line. Empty lines are ignored, but every other
+// line is used as it is, including any white space, because lists of
+// breached passwords contain no comments and passwords such as
+// "#1password" or " secret" are real entries.
+func LoadPasswordSet(path string) (PasswordSet, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	set := PasswordSet{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if password := scanner.Text(); password != "" {
+			set[strings.ToLower(password)] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// Blocked returns true iff password is in s, ignoring case.
+func (s PasswordSet) Blocked(password string) bool {
+	return s[strings.ToLower(password)]
+}
@@ -0,0 +1,107 @@
+// Copyright 2015 Alex Browne.  All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package forms
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPasswordStrength(t *testing.T) {
+	data := newData()
+	data.Add("username", "bobsmith")
+	data.Add("email", "robert@example.com")
+	data.Add("strong", "Tr0ub4dor&3x")
+	data.Add("short", "aB3$")
+	data.Add("letters", "correcthorsebattery")
+	data.Add("personal", "Bobsmith1990!")
+	data.Add("email_password", "Robert#2015x")
+	data.Add("common", "Password1!")
+	data.Add("predictable", "abcdefghijk")
+	policy := &PasswordPolicy{
+		MinLength:      10,
+		MaxLength:      64,
+		RequireUpper:   true,
+		RequireDigit:   true,
+		RequireSymbol:  true,
+		MinEntropy:     40,
+		DisallowFields: []string{"username", "email"},
+		Blocklist:      NewPasswordSet("password1!", "letmein"),
+	}
+
+	val := data.Validator()
+	val.PasswordStrength("strong", policy)
+	val.PasswordStrength("letters", nil)
+	if val.HasErrors() {
+		t.Errorf("Expected no errors but got errors: %v", val.Messages())
+	}
+
+	val.PasswordStrength("short", policy)
+	val.PasswordStrength("letters", policy)
+	val.PasswordStrength("personal", policy)
+	val.PasswordStrength("email_password", policy)
+	val.PasswordStrength("common", policy)
+	val.PasswordStrength("predictable", nil)
+	val.PasswordStrength("missing", nil)
+	expected := []string{
+		"short must be at least 10 characters long.",
+		"letters must contain an upper case letter, contain a digit, and contain a symbol.",
+		"personal must not contain your username.",
+		"email_password must not contain your email.",
+		"common must not be a commonly used password.",
+		"predictable must be harder to guess.",
+		"missing must be at least 8 characters long.",
+	}
+	if !reflect.DeepEqual(val.Messages(), expected) {
+		t.Errorf("Expected messages %v but got %v.", expected, val.Messages())
+	}
+	for _, code := range val.Codes() {
+		if code != "password" {
+			t.Errorf("Expected code password but got %q.", code)
+		}
+	}
+}
+
+func TestPasswordEntropy(t *testing.T) {
+	if got := PasswordEntropy(""); got != 0 {
+		t.Errorf("Expected 0 bits for an empty password but got %f.", got)
+	}
+	if PasswordEntropy("aaaaaaaaaaaa") >= PasswordEntropy("a")*2 {
+		t.Error("Expected repeated characters to add no entropy.")
+	}
+	if PasswordEntropy("123456789") >= PasswordEntropy("1")*2 {
+		t.Error("Expected sequences to add no entropy.")
+	}
+	if PasswordEntropy("Tr0ub4dor&3x") <= PasswordEntropy("troubadorxyz") {
+		t.Error("Expected more character classes to add entropy.")
+	}
+}
+
+func TestLoadPasswordSet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "forms-passwords-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "common.txt")
+	if err := ioutil.WriteFile(path, []byte("#1password\n123456\nPassword\n\n  qwerty \r\nletmein\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	set, err := LoadPasswordSet(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// lines are kept as they are, apart from the line endings
+	expected := PasswordSet{"#1password": true, "123456": true, "password": true, "  qwerty ": true, "letmein": true}
+	if !reflect.DeepEqual(set, expected) {
+		t.Errorf("Expected %v but got %v.", expected, set)
+	}
+	if !set.Blocked("PASSWORD") || set.Blocked("password1") {
+		t.Error("Expected Blocked to ignore case and match whole passwords only.")
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2015 Alex Browne.  All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package forms
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultThroughputGracePeriod is the default amount of time a request
+// body is given before ParseOptions.MinThroughput is enforced.
+const DefaultThroughputGracePeriod = 5 * time.Second
+
+// Progress describes how much of a request body has been read so far.
+type Progress struct {
+	// BytesRead is the number of bytes of the body read so far.
+	BytesRead int64
+	// TotalBytes is the length of the body as reported by
+	// req.ContentLength. It is -1 or 0 if the length is unknown.
+	TotalBytes int64
+	// Part is the form name of the multipart part currently being
+	// read, if any.
+	Part string
+	// Filename is the file name of the multipart part currently being
+	// read, if it is a file.
+	Filename string
+}
+
+// SlowUploadError is returned by ParseWithOptions when reading the request
+// body was aborted because it took longer than ParseOptions.ReadTimeout or
+// because its throughput fell below ParseOptions.MinThroughput.
+type SlowUploadError struct {
+	// BytesRead is the number of bytes of the body read before
+	// the upload was aborted.
+	BytesRead int64
+	// Elapsed is the amount of time spent reading the body.
+	Elapsed time.Duration
+	// Deadline is true iff the upload was aborted because of
+	// ReadTimeout rather than MinThroughput.
+	Deadline bool
+}
+
+// Error satisfies the error interface.
+func (e *SlowUploadError) Error() string {
+	if e.Deadline {
+		return fmt.Sprintf("forms: upload exceeded the read deadline after %d bytes in %v", e.BytesRead, e.Elapsed)
+	}
+	return fmt.Sprintf("forms: upload was too slow (%d bytes in %v)", e.BytesRead, e.Elapsed)
+}
+
+// Timeout returns true iff the upload was aborted because of ReadTimeout.
+// It makes SlowUploadError compatible with the net.Error interface.
+func (e *SlowUploadError) Timeout() bool {
+	return e.Deadline
+}
+
+// progressReader wraps a request body in order to report progress and
+// enforce ReadTimeout and MinThroughput.
+type progressReader struct {
+	body     io.ReadCloser
+	opts     *ParseOptions
+	total    int64
+	start    time.Time
+	read     int64
+	err      *SlowUploadError
+	mut      sync.Mutex
+	part     string
+	filename string
+}
+
+// usesProgressReader returns true iff any of the options which require a
+// progressReader are set.
+func (opts *ParseOptions) usesProgressReader() bool {
+	return opts.OnProgress != nil || opts.ReadTimeout > 0 || opts.MinThroughput > 0
+}
+
+// newProgressReader replaces the body of req with a progressReader.
+func newProgressReader(req *http.Request, opts *ParseOptions) *progressReader {
+	p := &progressReader{
+		body:  req.Body,
+		opts:  opts,
+		total: req.ContentLength,
+		start: time.Now(),
+	}
+	if req.Body != nil {
+		req.Body = p
+	}
+	return p
+}
+
+func (p *progressReader) Read(buf []byte) (int, error) {
+	if p.err != nil {
+		return 0, p.err
+	}
+	n, err := p.body.Read(buf)
+	p.read += int64(n)
+	elapsed := time.Since(p.start)
+	if p.opts.ReadTimeout > 0 && elapsed > p.opts.ReadTimeout {
+		p.err = &SlowUploadError{BytesRead: p.read, Elapsed: elapsed, Deadline: true}
+		return n, p.err
+	}
+	if p.opts.MinThroughput > 0 {
+		grace := p.opts.ThroughputGracePeriod
+		if grace == 0 {
+			grace = DefaultThroughputGracePeriod
+		}
+		if elapsed > grace && float64(p.read)/elapsed.Seconds() < float64(p.opts.MinThroughput) {
+			p.err = &SlowUploadError{BytesRead: p.read, Elapsed: elapsed}
+			return n, p.err
+		}
+	}
+	if p.opts.OnProgress != nil && n > 0 {
+		p.mut.Lock()
+		progress := Progress{
+			BytesRead:  p.read,
+			TotalBytes: p.total,
+			Part:       p.part,
+			Filename:   p.filename,
+		}
+		p.mut.Unlock()
+		p.opts.OnProgress(progress)
+	}
+	return n, err
+}
+
+func (p *progressReader) Close() error {
+	return p.body.Close()
+}
+
+// observePart is a partFunc which keeps track of the current part.
+func (p *progressReader) observePart(part *multipart.Part) error {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	p.part = part.FormName()
+	p.filename = part.FileName()
+	return nil
+}
+
+// abortErr returns the SlowUploadError which caused reading to be aborted,
+// if any, so that callers receive it directly instead of wrapped by the
+// multipart or json packages. Otherwise it returns err. It is safe to call
+// on a nil progressReader.
+func (p *progressReader) abortErr(err error) error {
+	if p != nil && p.err != nil {
+		return p.err
+	}
+	return err
+}
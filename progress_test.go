@@ -0,0 +1,123 @@
+// Copyright 2015 Alex Browne.  All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package forms
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowReader returns at most chunkSize bytes from r on each call to Read,
+// sleeping for delay beforehand.
+type slowReader struct {
+	r         io.Reader
+	chunkSize int
+	delay     time.Duration
+}
+
+func (s *slowReader) Read(buf []byte) (int, error) {
+	time.Sleep(s.delay)
+	if len(buf) > s.chunkSize {
+		buf = buf[:s.chunkSize]
+	}
+	return s.r.Read(buf)
+}
+
+// createSlowMultipartRequest returns a multipart request with a single file
+// whose body is delivered by a slowReader.
+func createSlowMultipartRequest(t *testing.T, content string, chunkSize int, delay time.Duration) (*http.Request, int64) {
+	req := createTestMultipartRequest(t, map[string]string{"name": "Bob"}, []testFilePart{
+		{key: "file", filename: "test.txt", content: []byte(content)},
+	})
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Body = ioutil.NopCloser(&slowReader{
+		r:         strings.NewReader(string(body)),
+		chunkSize: chunkSize,
+		delay:     delay,
+	})
+	req.ContentLength = int64(len(body))
+	return req, int64(len(body))
+}
+
+func TestParseProgress(t *testing.T) {
+	req, length := createSlowMultipartRequest(t, strings.Repeat("a", 10000), 512, 0)
+	progresses := []Progress{}
+	data, err := ParseWithOptions(req, &ParseOptions{
+		OnProgress: func(progress Progress) {
+			progresses = append(progresses, progress)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !data.FileExists("file") {
+		t.Error("Expected the file to be parsed but it was not.")
+	}
+	if len(progresses) < 2 {
+		t.Fatalf("Expected OnProgress to be called several times but it was called %d times.", len(progresses))
+	}
+	last := progresses[len(progresses)-1]
+	if last.BytesRead != length || last.TotalBytes != length {
+		t.Errorf("Expected the last progress to have read all %d bytes but got %+v.", length, last)
+	}
+	sawFile := false
+	for i, progress := range progresses {
+		if i > 0 && progress.BytesRead < progresses[i-1].BytesRead {
+			t.Errorf("Expected BytesRead to increase but got %d after %d.", progress.BytesRead, progresses[i-1].BytesRead)
+		}
+		if progress.Part == "file" && progress.Filename == "test.txt" {
+			sawFile = true
+		}
+	}
+	if !sawFile {
+		t.Error("Expected a progress report for the file part but got none.")
+	}
+}
+
+func TestParseReadTimeout(t *testing.T) {
+	req, _ := createSlowMultipartRequest(t, strings.Repeat("a", 1000), 10, 5*time.Millisecond)
+	_, err := ParseWithOptions(req, &ParseOptions{ReadTimeout: 30 * time.Millisecond})
+	slowErr, ok := err.(*SlowUploadError)
+	if !ok {
+		t.Fatalf("Expected a *SlowUploadError but got %T: %v", err, err)
+	}
+	if !slowErr.Timeout() {
+		t.Error("Expected Timeout() to be true but it was false.")
+	}
+	if slowErr.BytesRead == 0 {
+		t.Error("Expected BytesRead to be recorded but it was 0.")
+	}
+}
+
+func TestParseMinThroughput(t *testing.T) {
+	req, _ := createSlowMultipartRequest(t, strings.Repeat("a", 1000), 10, 5*time.Millisecond)
+	_, err := ParseWithOptions(req, &ParseOptions{
+		MinThroughput:         1000000,
+		ThroughputGracePeriod: 20 * time.Millisecond,
+	})
+	slowErr, ok := err.(*SlowUploadError)
+	if !ok {
+		t.Fatalf("Expected a *SlowUploadError but got %T: %v", err, err)
+	}
+	if slowErr.Timeout() {
+		t.Error("Expected Timeout() to be false but it was true.")
+	}
+
+	// fast enough
+	req, _ = createSlowMultipartRequest(t, "Hello!", 4096, 0)
+	if _, err := ParseWithOptions(req, &ParseOptions{
+		MinThroughput: 1,
+		ReadTimeout:   time.Minute,
+	}); err != nil {
+		t.Errorf("Expected no error but got %v.", err)
+	}
+}
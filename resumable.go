@@ -0,0 +1,691 @@
+// Copyright 2015 Alex Browne.  All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package forms
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// TusVersion is the version of the tus resumable upload protocol
+// implemented by UploadHandler.
+const TusVersion = "1.0.0"
+
+var (
+	// ErrUploadNotFound is returned by an UploadStore when there is no
+	// upload with the given id.
+	ErrUploadNotFound = errors.New("forms: upload not found")
+	// ErrUploadOffset is returned by UploadStore.WriteChunk when the given
+	// offset does not match the number of bytes already received.
+	ErrUploadOffset = errors.New("forms: upload offset does not match")
+	// ErrUploadFinished is returned by UploadStore.Finish when the upload
+	// has already been finished.
+	ErrUploadFinished = errors.New("forms: upload is already finished")
+)
+
+// UploadInfo describes a resumable upload.
+type UploadInfo struct {
+	// ID uniquely identifies the upload.
+	ID string `json:"id"`
+	// Length is the total size of the upload in bytes.
+	Length int64 `json:"length"`
+	// Offset is the number of bytes which have been received so far.
+	Offset int64 `json:"-"`
+	// Metadata holds the key-value pairs sent by the client in the
+	// Upload-Metadata header when the upload was created.
+	Metadata map[string]string `json:"metadata"`
+	// Finished is true once the upload is complete and has been passed
+	// to UploadHandler.OnComplete (or would have been, if OnComplete is
+	// nil). A finished upload cannot be written to.
+	Finished bool `json:"finished"`
+}
+
+// Complete returns true iff every byte of the upload has been received.
+func (info *UploadInfo) Complete() bool {
+	return info.Offset == info.Length
+}
+
+// UploadStore stores the contents of resumable uploads as they are
+// received by an UploadHandler.
+type UploadStore interface {
+	// Create creates a new, empty upload described by info.
+	Create(info *UploadInfo) error
+	// Info returns information about the upload with the given id,
+	// including its current offset. It returns ErrUploadNotFound if
+	// there is no such upload.
+	Info(id string) (*UploadInfo, error)
+	// WriteChunk appends the contents of r to the upload with the given
+	// id, starting at offset, and returns the number of bytes written.
+	// It must not write past the length of the upload. It returns
+	// ErrUploadOffset if offset is not equal to the current offset of
+	// the upload. If r returns an error, any bytes which were already
+	// read should be kept so that the upload can be resumed.
+	WriteChunk(id string, offset int64, r io.Reader) (int64, error)
+	// Open returns a reader for the contents of the upload with the
+	// given id.
+	Open(id string) (io.ReadCloser, error)
+	// Finish marks the complete upload with the given id as finished.
+	// It returns ErrUploadFinished if the upload was already finished,
+	// so that only one caller can finish an upload.
+	Finish(id string) error
+	// Remove deletes the upload with the given id and its contents. It
+	// returns ErrUploadNotFound if there is no such upload.
+	Remove(id string) error
+}
+
+// DiskUploadStore is an UploadStore which keeps uploads in a directory on
+// the local filesystem. For each upload, it stores the contents in a file
+// named <id>.bin and the upload info in a file named <id>.info.
+type DiskUploadStore struct {
+	// Dir is the directory in which uploads are stored.
+	Dir string
+	// locks holds an uploadLock for each upload id which is currently in
+	// use, so that the same upload is never changed concurrently. mut
+	// protects locks.
+	mut   sync.Mutex
+	locks map[string]*uploadLock
+}
+
+// uploadLock is a mutex which counts the number of goroutines using it,
+// so that it can be deleted once it is no longer needed.
+type uploadLock struct {
+	sync.Mutex
+	refs int
+}
+
+// NewDiskUploadStore returns a DiskUploadStore which stores uploads in dir.
+// The directory is created when the first upload is created.
+func NewDiskUploadStore(dir string) *DiskUploadStore {
+	return &DiskUploadStore{Dir: dir}
+}
+
+// lock locks the upload with the given id and returns a function which
+// unlocks it. The lock is deleted once no goroutine is using it, so locks
+// are not kept for uploads which are finished, removed or abandoned.
+func (s *DiskUploadStore) lock(id string) func() {
+	s.mut.Lock()
+	if s.locks == nil {
+		s.locks = map[string]*uploadLock{}
+	}
+	l, found := s.locks[id]
+	if !found {
+		l = &uploadLock{}
+		s.locks[id] = l
+	}
+	l.refs++
+	s.mut.Unlock()
+	l.Lock()
+	return func() {
+		l.Unlock()
+		s.mut.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(s.locks, id)
+		}
+		s.mut.Unlock()
+	}
+}
+
+func (s *DiskUploadStore) paths(id string) (string, string, error) {
+	if !isValidUploadID(id) {
+		return "", "", ErrUploadNotFound
+	}
+	base := filepath.Join(s.Dir, id)
+	return base + ".bin", base + ".info", nil
+}
+
+// Create creates a new, empty upload described by info.
+func (s *DiskUploadStore) Create(info *UploadInfo) error {
+	binPath, infoPath, err := s.paths(info.ID)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return err
+	}
+	bin, err := os.OpenFile(binPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if err := bin.Close(); err != nil {
+		return err
+	}
+	return s.writeInfo(infoPath, info)
+}
+
+// writeInfo writes info to the file at infoPath. The info is written to a
+// temporary file which is then renamed into place, so Info never sees a
+// partially written file, even if the process crashes while writing.
+func (s *DiskUploadStore) writeInfo(infoPath string, info *UploadInfo) error {
+	encoded, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(s.Dir, ".info-")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	if err := writeAndClose(tmp, bytes.NewReader(encoded)); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if err := os.Chmod(tmpName, 0644); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if err := os.Rename(tmpName, infoPath); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	return nil
+}
+
+// Info returns information about the upload with the given id.
+func (s *DiskUploadStore) Info(id string) (*UploadInfo, error) {
+	binPath, infoPath, err := s.paths(id)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := ioutil.ReadFile(infoPath)
+	if os.IsNotExist(err) {
+		return nil, ErrUploadNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	info := &UploadInfo{}
+	if err := json.Unmarshal(encoded, info); err != nil {
+		return nil, err
+	}
+	stat, err := os.Stat(binPath)
+	if os.IsNotExist(err) {
+		return nil, ErrUploadNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	info.Offset = stat.Size()
+	return info, nil
+}
+
+// WriteChunk appends the contents of r to the upload with the given id.
+func (s *DiskUploadStore) WriteChunk(id string, offset int64, r io.Reader) (int64, error) {
+	unlock := s.lock(id)
+	defer unlock()
+	info, err := s.Info(id)
+	if err != nil {
+		return 0, err
+	}
+	if info.Finished {
+		return 0, ErrUploadFinished
+	}
+	if offset != info.Offset {
+		return 0, ErrUploadOffset
+	}
+	binPath, _, err := s.paths(id)
+	if err != nil {
+		return 0, err
+	}
+	bin, err := os.OpenFile(binPath, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(bin, io.LimitReader(r, info.Length-info.Offset))
+	if closeErr := bin.Close(); err == nil {
+		err = closeErr
+	}
+	return n, err
+}
+
+// Open returns a reader for the contents of the upload with the given id.
+func (s *DiskUploadStore) Open(id string) (io.ReadCloser, error) {
+	binPath, _, err := s.paths(id)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(binPath)
+	if os.IsNotExist(err) {
+		return nil, ErrUploadNotFound
+	}
+	return file, err
+}
+
+// Finish marks the complete upload with the given id as finished.
+func (s *DiskUploadStore) Finish(id string) error {
+	unlock := s.lock(id)
+	defer unlock()
+	info, err := s.Info(id)
+	if err != nil {
+		return err
+	}
+	if info.Finished {
+		return ErrUploadFinished
+	}
+	if !info.Complete() {
+		return errors.New("forms: cannot finish an incomplete upload")
+	}
+	info.Finished = true
+	_, infoPath, err := s.paths(id)
+	if err != nil {
+		return err
+	}
+	return s.writeInfo(infoPath, info)
+}
+
+// Remove deletes the upload with the given id and its contents.
+func (s *DiskUploadStore) Remove(id string) error {
+	unlock := s.lock(id)
+	defer unlock()
+	binPath, infoPath, err := s.paths(id)
+	if err != nil {
+		return err
+	}
+	// remove the info file first, so that a partially removed upload is
+	// not found by Info
+	if err := os.Remove(infoPath); os.IsNotExist(err) {
+		return ErrUploadNotFound
+	} else if err != nil {
+		return err
+	}
+	if err := os.Remove(binPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// UploadCompleteFunc is called by an UploadHandler once every byte of an
+// upload has been received. data holds the uploaded file under the key
+// given by UploadHandler.FileKey, along with any metadata sent by the
+// client as Values, so it can be checked with the usual Validator methods.
+// The file is only guaranteed to be available until the function returns.
+// It is called exactly once for each upload. If the function returns an
+// error, the upload is removed from the store and the client receives a
+// 422 response with the error message as the body.
+type UploadCompleteFunc func(data *Data, info *UploadInfo) error
+
+// UploadHandler is an http.Handler which implements the core of the tus
+// resumable upload protocol (https://tus.io) along with the creation
+// extension. Clients create an upload with a POST request to BasePath,
+// send chunks with PATCH requests to the returned location, and can find
+// out how much has been received with a HEAD request, so that an
+// interrupted upload can be resumed where it left off. The termination
+// extension is supported too, so clients can abandon an upload with a
+// DELETE request.
+//
+// Uploads which were finished successfully are kept in Store; remove them
+// with Store.Remove once they are no longer needed. Uploads which are never
+// completed by the client stay in Store until they are removed in the same
+// way, e.g. by a periodic job.
+type UploadHandler struct {
+	// Store is where the contents of uploads are kept.
+	Store UploadStore
+	// BasePath is the path at which the handler is mounted, e.g.
+	// "/files/". Uploads are available at BasePath + id.
+	BasePath string
+	// MaxSize is the maximum allowed length of an upload in bytes.
+	// A MaxSize of 0 means there is no limit.
+	MaxSize int64
+	// FileKey is the key of the uploaded file in the Data passed to
+	// OnComplete. It defaults to "file".
+	FileKey string
+	// MaxMemory is the maximum number of bytes of a completed upload
+	// which are held in memory when it is passed to OnComplete. The
+	// rest is stored in a temporary file. It defaults to
+	// DefaultMaxFormSize.
+	MaxMemory int64
+	// OnComplete, if not nil, is called once an upload is complete.
+	OnComplete UploadCompleteFunc
+}
+
+// NewUploadHandler returns an UploadHandler which stores uploads in store
+// and is mounted at basePath.
+func NewUploadHandler(store UploadStore, basePath string) *UploadHandler {
+	return &UploadHandler{
+		Store:    store,
+		BasePath: basePath,
+	}
+}
+
+// ServeHTTP satisfies the http.Handler interface.
+func (h *UploadHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Tus-Resumable", TusVersion)
+	if req.Method == "OPTIONS" {
+		w.Header().Set("Tus-Version", TusVersion)
+		w.Header().Set("Tus-Extension", "creation,termination")
+		if h.MaxSize > 0 {
+			w.Header().Set("Tus-Max-Size", strconv.FormatInt(h.MaxSize, 10))
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if req.Header.Get("Tus-Resumable") != TusVersion {
+		w.Header().Set("Tus-Version", TusVersion)
+		http.Error(w, "Unsupported version of the tus protocol.", http.StatusPreconditionFailed)
+		return
+	}
+	basePath := strings.TrimSuffix(h.BasePath, "/")
+	if !strings.HasPrefix(req.URL.Path, basePath) {
+		http.NotFound(w, req)
+		return
+	}
+	id := strings.Trim(strings.TrimPrefix(req.URL.Path, basePath), "/")
+	switch {
+	case id == "" && req.Method == "POST":
+		h.create(w, req)
+	case id != "" && req.Method == "HEAD":
+		h.head(w, req, id)
+	case id != "" && req.Method == "PATCH":
+		h.patch(w, req, id)
+	case id != "" && req.Method == "DELETE":
+		h.terminate(w, req, id)
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *UploadHandler) create(w http.ResponseWriter, req *http.Request) {
+	length, err := strconv.ParseInt(req.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		http.Error(w, "Upload-Length must be a non-negative integer.", http.StatusBadRequest)
+		return
+	}
+	if h.MaxSize > 0 && length > h.MaxSize {
+		http.Error(w, fmt.Sprintf("Upload-Length cannot be more than %d.", h.MaxSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+	metadata, err := parseUploadMetadata(req.Header.Get("Upload-Metadata"))
+	if err != nil {
+		http.Error(w, "Upload-Metadata is not formatted correctly.", http.StatusBadRequest)
+		return
+	}
+	// the filename is used in a header when the upload is passed to
+	// OnComplete, so it is checked now rather than failing after every
+	// byte has been received
+	if strings.IndexFunc(metadata["filename"], unicode.IsControl) != -1 {
+		http.Error(w, "The filename in Upload-Metadata cannot contain control characters.", http.StatusBadRequest)
+		return
+	}
+	id, err := generateUploadID()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	info := &UploadInfo{
+		ID:       id,
+		Length:   length,
+		Metadata: metadata,
+	}
+	if err := h.Store.Create(info); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Location", strings.TrimSuffix(h.BasePath, "/")+"/"+id)
+	if info.Complete() && !h.finish(w, info) {
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (h *UploadHandler) head(w http.ResponseWriter, req *http.Request, id string) {
+	info, err := h.Store.Info(id)
+	if err != nil {
+		writeUploadStoreError(w, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(info.Length, 10))
+	if len(info.Metadata) > 0 {
+		w.Header().Set("Upload-Metadata", formatUploadMetadata(info.Metadata))
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *UploadHandler) patch(w http.ResponseWriter, req *http.Request, id string) {
+	if req.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		http.Error(w, "Content-Type must be application/offset+octet-stream.", http.StatusUnsupportedMediaType)
+		return
+	}
+	offset, err := strconv.ParseInt(req.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		http.Error(w, "Upload-Offset must be a non-negative integer.", http.StatusBadRequest)
+		return
+	}
+	info, err := h.Store.Info(id)
+	if err != nil {
+		writeUploadStoreError(w, err)
+		return
+	}
+	if info.Finished || info.Complete() {
+		writeUploadStoreError(w, ErrUploadFinished)
+		return
+	}
+	if offset != info.Offset {
+		writeUploadStoreError(w, ErrUploadOffset)
+		return
+	}
+	n, err := h.Store.WriteChunk(id, offset, req.Body)
+	if err != nil {
+		writeUploadStoreError(w, err)
+		return
+	}
+	info.Offset += n
+	w.Header().Set("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+	if info.Complete() && !h.finish(w, info) {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *UploadHandler) terminate(w http.ResponseWriter, req *http.Request, id string) {
+	if err := h.Store.Remove(id); err != nil {
+		writeUploadStoreError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// finish marks the complete upload described by info as finished and
+// passes it to h.OnComplete. If either fails, it writes an error response
+// and returns false. Uploads rejected by h.OnComplete are removed.
+func (h *UploadHandler) finish(w http.ResponseWriter, info *UploadInfo) bool {
+	if err := h.Store.Finish(info.ID); err != nil {
+		writeUploadStoreError(w, err)
+		return false
+	}
+	info.Finished = true
+	if err := h.complete(info); err != nil {
+		// the upload can never be finished again, so there is no point
+		// in keeping it
+		h.Store.Remove(info.ID)
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return false
+	}
+	return true
+}
+
+// complete calls h.OnComplete (if any) for the upload described by info.
+func (h *UploadHandler) complete(info *UploadInfo) error {
+	if h.OnComplete == nil {
+		return nil
+	}
+	file, err := h.Store.Open(info.ID)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	fileKey := h.FileKey
+	if fileKey == "" {
+		fileKey = "file"
+	}
+	maxMemory := h.MaxMemory
+	if maxMemory == 0 {
+		maxMemory = DefaultMaxFormSize
+	}
+	filename := info.Metadata["filename"]
+	if filename == "" {
+		filename = info.ID
+	}
+	form, err := newMultipartForm(fileKey, filename, info.Metadata["filetype"], file, maxMemory)
+	if err != nil {
+		return err
+	}
+	defer form.RemoveAll()
+	data := newData()
+	for key, value := range info.Metadata {
+		data.Add(key, value)
+	}
+	data.AddFile(fileKey, form.File[fileKey][0])
+	return h.OnComplete(data, info)
+}
+
+// newMultipartForm returns a multipart.Form containing the contents of r
+// as a single file with the given key. multipart.FileHeader can only be
+// created by parsing a multipart body, so this encodes the file and parses
+// it again in a streaming fashion. The caller is responsible for calling
+// RemoveAll on the returned form.
+func newMultipartForm(key string, filename string, contentType string, r io.Reader, maxMemory int64) (*multipart.Form, error) {
+	pipeReader, pipeWriter := io.Pipe()
+	writer := multipart.NewWriter(pipeWriter)
+	go func() {
+		partHeader := textproto.MIMEHeader{}
+		partHeader.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, escapeQuotes(key), escapeQuotes(filename)))
+		partHeader.Set("Content-Type", cleanContentType(contentType))
+		part, err := writer.CreatePart(partHeader)
+		if err == nil {
+			_, err = io.Copy(part, r)
+		}
+		if err == nil {
+			err = writer.Close()
+		}
+		pipeWriter.CloseWithError(err)
+	}()
+	form, err := multipart.NewReader(pipeReader, writer.Boundary()).ReadForm(maxMemory)
+	// Make sure the writing goroutine is never left blocked.
+	pipeReader.Close()
+	if err != nil {
+		return nil, err
+	}
+	if len(form.File[key]) == 0 {
+		form.RemoveAll()
+		return nil, errors.New("forms: could not create file from upload")
+	}
+	return form, nil
+}
+
+// cleanContentType returns contentType in canonical form, or
+// "application/octet-stream" if it is empty or cannot be parsed, so that
+// a content type given by a client is always safe to use in a header.
+func cleanContentType(contentType string) string {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "application/octet-stream"
+	}
+	if cleaned := mime.FormatMediaType(mediaType, params); cleaned != "" {
+		return cleaned
+	}
+	return "application/octet-stream"
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
+
+func writeUploadStoreError(w http.ResponseWriter, err error) {
+	switch err {
+	case ErrUploadNotFound:
+		http.NotFound(w, nil)
+	case ErrUploadOffset:
+		http.Error(w, "Upload-Offset does not match the current offset.", http.StatusConflict)
+	case ErrUploadFinished:
+		http.Error(w, "The upload is already complete.", http.StatusForbidden)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// parseUploadMetadata parses the value of an Upload-Metadata header, which
+// consists of comma-separated pairs of a key and a base64-encoded value
+// separated by a space. The value may be omitted.
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := map[string]string{}
+	for _, pair := range strings.Split(header, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.Fields(pair)
+		switch len(parts) {
+		case 1:
+			metadata[parts[0]] = ""
+		case 2:
+			value, err := base64.StdEncoding.DecodeString(parts[1])
+			if err != nil {
+				return nil, err
+			}
+			metadata[parts[0]] = string(value)
+		default:
+			return nil, fmt.Errorf("forms: invalid metadata pair %q", pair)
+		}
+	}
+	return metadata, nil
+}
+
+// formatUploadMetadata is the inverse of parseUploadMetadata. Keys are
+// sorted so that the result is deterministic.
+func formatUploadMetadata(metadata map[string]string) string {
+	keys := []string{}
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := []string{}
+	for _, key := range keys {
+		if metadata[key] == "" {
+			pairs = append(pairs, key)
+		} else {
+			pairs = append(pairs, key+" "+base64.StdEncoding.EncodeToString([]byte(metadata[key])))
+		}
+	}
+	return strings.Join(pairs, ",")
+}
+
+func generateUploadID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// isValidUploadID returns true iff id could have been generated by
+// generateUploadID. This keeps ids from being used to access arbitrary
+// paths.
+func isValidUploadID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
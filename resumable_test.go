@@ -0,0 +1,366 @@
+// Copyright 2015 Alex Browne.  All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package forms
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func doUploadRequest(handler http.Handler, method string, path string, body io.Reader, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, body)
+	req.Header.Set("Tus-Resumable", TusVersion)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+	return res
+}
+
+func TestUploadHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "forms-uploads-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var completedData *Data
+	var completedContents string
+	handler := NewUploadHandler(NewDiskUploadStore(dir), "/files/")
+	handler.MaxSize = 100
+	handler.OnComplete = func(data *Data, info *UploadInfo) error {
+		completedData = data
+		contents, err := data.GetFileBytes("file")
+		completedContents = string(contents)
+		if err != nil {
+			return err
+		}
+		val := data.Validator()
+		val.Require("title")
+		val.RequireFile("file")
+		val.AcceptFileExts("file", "txt")
+		val.MatchContentType("file")
+		if val.HasErrors() {
+			return errors.New(strings.Join(val.Messages(), " "))
+		}
+		return nil
+	}
+
+	res := doUploadRequest(handler, "OPTIONS", "/files/", nil, nil)
+	if res.Code != http.StatusNoContent || res.Header().Get("Tus-Max-Size") != "100" {
+		t.Errorf("Unexpected response to OPTIONS: %d %v", res.Code, res.Header())
+	}
+
+	res = doUploadRequest(handler, "POST", "/files/", nil, map[string]string{
+		"Upload-Length":   "101",
+		"Upload-Metadata": "",
+	})
+	if res.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status %d for an upload which is too large but got %d.", http.StatusRequestEntityTooLarge, res.Code)
+	}
+
+	metadata := "filename " + base64.StdEncoding.EncodeToString([]byte("hello.txt")) +
+		",title " + base64.StdEncoding.EncodeToString([]byte("Greeting")) +
+		",empty"
+	res = doUploadRequest(handler, "POST", "/files/", nil, map[string]string{
+		"Upload-Length":   "12",
+		"Upload-Metadata": metadata,
+	})
+	if res.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d for POST but got %d: %s", http.StatusCreated, res.Code, res.Body.String())
+	}
+	location := res.Header().Get("Location")
+	if !strings.HasPrefix(location, "/files/") {
+		t.Fatalf("Expected Location to start with /files/ but got %q.", location)
+	}
+
+	res = doUploadRequest(handler, "HEAD", location, nil, nil)
+	if res.Code != http.StatusOK || res.Header().Get("Upload-Offset") != "0" || res.Header().Get("Upload-Length") != "12" {
+		t.Errorf("Unexpected response to HEAD: %d %v", res.Code, res.Header())
+	}
+
+	chunkHeaders := func(offset string) map[string]string {
+		return map[string]string{
+			"Content-Type":  "application/offset+octet-stream",
+			"Upload-Offset": offset,
+		}
+	}
+	res = doUploadRequest(handler, "PATCH", location, strings.NewReader("Hello, "), chunkHeaders("0"))
+	if res.Code != http.StatusNoContent || res.Header().Get("Upload-Offset") != "7" {
+		t.Errorf("Unexpected response to first PATCH: %d %v", res.Code, res.Header())
+	}
+	res = doUploadRequest(handler, "PATCH", location, strings.NewReader("Hello, "), chunkHeaders("0"))
+	if res.Code != http.StatusConflict {
+		t.Errorf("Expected status %d for a PATCH with the wrong offset but got %d.", http.StatusConflict, res.Code)
+	}
+	res = doUploadRequest(handler, "HEAD", location, nil, nil)
+	if res.Header().Get("Upload-Offset") != "7" {
+		t.Errorf("Expected Upload-Offset to be 7 after resuming but got %q.", res.Header().Get("Upload-Offset"))
+	}
+	if completedData != nil {
+		t.Error("Expected OnComplete not to be called before the upload was complete.")
+	}
+	// send more than the upload length, which should be ignored
+	res = doUploadRequest(handler, "PATCH", location, strings.NewReader("world!!!!"), chunkHeaders("7"))
+	if res.Code != http.StatusNoContent || res.Header().Get("Upload-Offset") != "12" {
+		t.Errorf("Unexpected response to final PATCH: %d %v %s", res.Code, res.Header(), res.Body.String())
+	}
+	if completedData == nil {
+		t.Fatal("Expected OnComplete to be called but it was not.")
+	}
+	if completedContents != "Hello, world" {
+		t.Errorf(`Expected file contents to be "Hello, world" but got %q.`, completedContents)
+	}
+	expectedValues := map[string][]string{"filename": {"hello.txt"}, "title": {"Greeting"}, "empty": {""}}
+	if !reflect.DeepEqual(map[string][]string(completedData.Values), expectedValues) {
+		t.Errorf("Expected Values to be %v but got %v.", expectedValues, completedData.Values)
+	}
+}
+
+func TestUploadHandlerErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "forms-uploads-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	handler := NewUploadHandler(NewDiskUploadStore(dir), "/files")
+	handler.OnComplete = func(data *Data, info *UploadInfo) error {
+		val := data.Validator()
+		val.AcceptFileExts("file", "png")
+		if val.HasErrors() {
+			return errors.New(val.Messages()[0])
+		}
+		return nil
+	}
+
+	req := httptest.NewRequest("POST", "/files", nil)
+	req.Header.Set("Upload-Length", "1")
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+	if res.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected status %d without Tus-Resumable but got %d.", http.StatusPreconditionFailed, res.Code)
+	}
+
+	for _, path := range []string{"/files/" + strings.Repeat("0", 32), "/files/../../etc/passwd"} {
+		res = doUploadRequest(handler, "HEAD", path, nil, nil)
+		if res.Code != http.StatusNotFound {
+			t.Errorf("Expected status %d for HEAD %s but got %d.", http.StatusNotFound, path, res.Code)
+		}
+	}
+
+	res = doUploadRequest(handler, "POST", "/files", nil, map[string]string{
+		"Upload-Length":   "5",
+		"Upload-Metadata": "filename " + base64.StdEncoding.EncodeToString([]byte("hello.txt")),
+	})
+	location := res.Header().Get("Location")
+	res = doUploadRequest(handler, "PATCH", location, strings.NewReader("Hello"), map[string]string{
+		"Upload-Offset": "0",
+	})
+	if res.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected status %d without Content-Type but got %d.", http.StatusUnsupportedMediaType, res.Code)
+	}
+	res = doUploadRequest(handler, "PATCH", location, strings.NewReader("Hello"), map[string]string{
+		"Content-Type":  "application/offset+octet-stream",
+		"Upload-Offset": "0",
+	})
+	if res.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status %d when OnComplete fails but got %d.", http.StatusUnprocessableEntity, res.Code)
+	}
+	if !strings.Contains(res.Body.String(), "The file extension .txt is not allowed.") {
+		t.Errorf("Expected the validation message in the body but got %q.", res.Body.String())
+	}
+	// uploads rejected by OnComplete are removed
+	res = doUploadRequest(handler, "HEAD", location, nil, nil)
+	if res.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for HEAD after OnComplete failed but got %d.", http.StatusNotFound, res.Code)
+	}
+}
+
+func TestUploadHandlerMetadata(t *testing.T) {
+	dir, err := ioutil.TempDir("", "forms-uploads-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	handler := NewUploadHandler(NewDiskUploadStore(dir), "/files/")
+	var contentType string
+	handler.OnComplete = func(data *Data, info *UploadInfo) error {
+		contentType = data.GetFile("file").Header.Get("Content-Type")
+		return nil
+	}
+	encode := func(value string) string {
+		return base64.StdEncoding.EncodeToString([]byte(value))
+	}
+
+	res := doUploadRequest(handler, "POST", "/files/", nil, map[string]string{
+		"Upload-Length":   "5",
+		"Upload-Metadata": "filename " + encode("evil\r\nContent-Type: text/html.txt"),
+	})
+	if res.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for a filename with control characters but got %d.", http.StatusBadRequest, res.Code)
+	}
+
+	table := []struct {
+		filetype string
+		expected string
+	}{
+		{"text/plain; charset=utf-8", "text/plain; charset=utf-8"},
+		{"text/plain\r\nX-Evil: 1", "application/octet-stream"},
+		{"not a type", "application/octet-stream"},
+		{"", "application/octet-stream"},
+	}
+	for _, test := range table {
+		res = doUploadRequest(handler, "POST", "/files/", nil, map[string]string{
+			"Upload-Length":   "5",
+			"Upload-Metadata": "filename " + encode("hello.txt") + ",filetype " + encode(test.filetype),
+		})
+		if res.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d for POST but got %d: %s", http.StatusCreated, res.Code, res.Body.String())
+		}
+		res = doUploadRequest(handler, "PATCH", res.Header().Get("Location"), strings.NewReader("Hello"), map[string]string{
+			"Content-Type":  "application/offset+octet-stream",
+			"Upload-Offset": "0",
+		})
+		if res.Code != http.StatusNoContent {
+			t.Errorf("Expected status %d for filetype %q but got %d: %s", http.StatusNoContent, test.filetype, res.Code, res.Body.String())
+		}
+		if contentType != test.expected {
+			t.Errorf("Expected content type %q for filetype %q but got %q.", test.expected, test.filetype, contentType)
+		}
+	}
+}
+
+func TestUploadHandlerCompletesOnce(t *testing.T) {
+	dir, err := ioutil.TempDir("", "forms-uploads-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := NewDiskUploadStore(dir)
+	handler := NewUploadHandler(store, "/files/")
+	calls := 0
+	handler.OnComplete = func(data *Data, info *UploadInfo) error {
+		calls++
+		return nil
+	}
+
+	res := doUploadRequest(handler, "POST", "/files/", nil, map[string]string{
+		"Upload-Length": "3",
+	})
+	location := res.Header().Get("Location")
+	chunkHeaders := map[string]string{
+		"Content-Type":  "application/offset+octet-stream",
+		"Upload-Offset": "0",
+	}
+	res = doUploadRequest(handler, "PATCH", location, strings.NewReader("abc"), chunkHeaders)
+	if res.Code != http.StatusNoContent {
+		t.Fatalf("Expected status %d for PATCH but got %d: %s", http.StatusNoContent, res.Code, res.Body.String())
+	}
+	chunkHeaders["Upload-Offset"] = "3"
+	for i := 0; i < 2; i++ {
+		res = doUploadRequest(handler, "PATCH", location, strings.NewReader(""), chunkHeaders)
+		if res.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d for a PATCH to a complete upload but got %d.", http.StatusForbidden, res.Code)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected OnComplete to be called once but it was called %d times.", calls)
+	}
+	id := strings.TrimPrefix(location, "/files/")
+	if info, err := store.Info(id); err != nil {
+		t.Error(err)
+	} else if !info.Finished {
+		t.Error("Expected the upload to be recorded as finished but it was not.")
+	}
+	if err := store.Finish(id); err != ErrUploadFinished {
+		t.Errorf("Expected ErrUploadFinished when finishing twice but got %v.", err)
+	}
+	if len(store.locks) != 0 {
+		t.Errorf("Expected no locks to be kept but got %d.", len(store.locks))
+	}
+	// the info file is written to a temporary file and renamed into place
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, file := range files {
+		names = append(names, file.Name())
+	}
+	expectedNames := []string{id + ".bin", id + ".info"}
+	if !reflect.DeepEqual(names, expectedNames) {
+		t.Errorf("Expected the upload directory to contain %v but got %v.", expectedNames, names)
+	}
+}
+
+func TestUploadHandlerTerminate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "forms-uploads-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	handler := NewUploadHandler(NewDiskUploadStore(dir), "/files/")
+
+	res := doUploadRequest(handler, "OPTIONS", "/files/", nil, nil)
+	if got := res.Header().Get("Tus-Extension"); got != "creation,termination" {
+		t.Errorf(`Expected Tus-Extension to be "creation,termination" but got %q.`, got)
+	}
+	res = doUploadRequest(handler, "POST", "/files/", nil, map[string]string{
+		"Upload-Length": "10",
+	})
+	location := res.Header().Get("Location")
+	res = doUploadRequest(handler, "PATCH", location, strings.NewReader("Hello"), map[string]string{
+		"Content-Type":  "application/offset+octet-stream",
+		"Upload-Offset": "0",
+	})
+	if res.Code != http.StatusNoContent {
+		t.Fatalf("Expected status %d for PATCH but got %d: %s", http.StatusNoContent, res.Code, res.Body.String())
+	}
+	res = doUploadRequest(handler, "DELETE", location, nil, nil)
+	if res.Code != http.StatusNoContent {
+		t.Errorf("Expected status %d for DELETE but got %d.", http.StatusNoContent, res.Code)
+	}
+	res = doUploadRequest(handler, "HEAD", location, nil, nil)
+	if res.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for HEAD after DELETE but got %d.", http.StatusNotFound, res.Code)
+	}
+	res = doUploadRequest(handler, "DELETE", location, nil, nil)
+	if res.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for a second DELETE but got %d.", http.StatusNotFound, res.Code)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Errorf("Expected the upload directory to be empty but it contains %d files.", len(files))
+	}
+}
+
+func TestParseUploadMetadata(t *testing.T) {
+	metadata, err := parseUploadMetadata("filename d29ybGRfZG9taW5hdGlvbl9wbGFuLnBkZg==,is_confidential")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"filename": "world_domination_plan.pdf", "is_confidential": ""}
+	if !reflect.DeepEqual(metadata, expected) {
+		t.Errorf("Expected %v but got %v.", expected, metadata)
+	}
+	if formatted := formatUploadMetadata(metadata); formatted != "filename d29ybGRfZG9taW5hdGlvbl9wbGFuLnBkZg==,is_confidential" {
+		t.Errorf("formatUploadMetadata was incorrect. Got %q.", formatted)
+	}
+	if _, err := parseUploadMetadata("filename not-base64!"); err == nil {
+		t.Error("Expected an error for invalid base64 but got none.")
+	}
+}
@@ -0,0 +1,524 @@
+// Copyright 2015 Alex Browne.  All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package forms
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FieldPlaceholder is replaced by the name of the field being validated in
+// the messages of errors returned by a Rule.
+const FieldPlaceholder = "{field}"
+
+// Rule is a reusable validation rule. Rules can be applied directly with
+// Validator.Apply, or registered by name with RegisterRule so that they can
+// be used in tag strings with Validator.Check and Validator.CheckStruct,
+// just like the built in rules.
+type Rule interface {
+	// Validate returns nil if value, the first value of a field in data, is
+	// valid. Otherwise it returns an error, which should be a *RuleError
+	// so that it has a code. data may be used to compare value with other
+	// fields.
+	Validate(ctx context.Context, value string, data *Data) error
+}
+
+// RuleFunc is an adapter which allows an ordinary function to be used as a
+// Rule.
+type RuleFunc func(ctx context.Context, value string, data *Data) error
+
+// Validate calls f(ctx, value, data).
+func (f RuleFunc) Validate(ctx context.Context, value string, data *Data) error {
+	return f(ctx, value, data)
+}
+
+// RuleError is the error returned by a Rule when a value is invalid.
+type RuleError struct {
+	// Code is a machine-readable code for the error, e.g. "sku".
+	Code string
+	// Message is a user-readable message, in which FieldPlaceholder is
+	// replaced by the name of the field, e.g. "{field} must be a valid
+	// SKU."
+	Message string
+	// Params holds any parameters of the rule, e.g. {"min": "3"}, which
+	// clients can use to build their own messages.
+	Params map[string]string
+}
+
+func (e *RuleError) Error() string {
+	return e.Message
+}
+
+// Apply will add an error to the Validator if rule returns an error for the
+// first element of data.Values[field]. If the error is a *RuleError, the
+// ValidationResult has its message, code and params. Otherwise, the message
+// is the text of the error. In either case, FieldPlaceholder in the message
+// is replaced by field.
+func (v *Validator) Apply(field string, rule Rule) *ValidationResult {
+	return v.ApplyContext(context.Background(), field, rule)
+}
+
+// ApplyContext is like Apply but passes ctx to rule.
+func (v *Validator) ApplyContext(ctx context.Context, field string, rule Rule) *ValidationResult {
+	err := rule.Validate(ctx, v.data.Get(field), v.data)
+	if err == nil {
+		return validationOk
+	}
+	return v.addRuleError(field, err)
+}
+
+func (v *Validator) addRuleError(field string, err error) *ValidationResult {
+	if ruleErr, ok := err.(*RuleError); ok {
+		msg := strings.Replace(ruleErr.Message, FieldPlaceholder, field, -1)
+		var params map[string]string
+		if ruleErr.Params != nil {
+			// copy the params, since a rule may return the same ones
+			// every time
+			params = map[string]string{}
+			for key, value := range ruleErr.Params {
+				params[key] = value
+			}
+		}
+		return v.AddError(field, msg).Code(ruleErr.Code).Params(params)
+	}
+	return v.AddError(field, strings.Replace(err.Error(), FieldPlaceholder, field, -1))
+}
+
+// Not returns a Rule which fails if rule passes, with the code "not" and a
+// generic message. Use ValidationResult.Message to give a better one.
+func Not(rule Rule) Rule {
+	return RuleFunc(func(ctx context.Context, value string, data *Data) error {
+		if rule.Validate(ctx, value, data) == nil {
+			return &RuleError{Code: "not", Message: FieldPlaceholder + " is invalid."}
+		}
+		return nil
+	})
+}
+
+// And returns a Rule which passes if all of rules pass. It returns the
+// error of the first rule which fails, and does not check the others.
+func And(rules ...Rule) Rule {
+	return RuleFunc(func(ctx context.Context, value string, data *Data) error {
+		for _, rule := range rules {
+			if err := rule.Validate(ctx, value, data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Or returns a Rule which passes if any of rules passes. If they all fail,
+// it returns the error of the first rule.
+func Or(rules ...Rule) Rule {
+	return RuleFunc(func(ctx context.Context, value string, data *Data) error {
+		var firstErr error
+		for _, rule := range rules {
+			err := rule.Validate(ctx, value, data)
+			if err == nil {
+				return nil
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		return firstErr
+	})
+}
+
+// Optional returns a Rule which passes if the value is empty or consists of
+// only whitespace, and otherwise applies rule.
+func Optional(rule Rule) Rule {
+	return RuleFunc(func(ctx context.Context, value string, data *Data) error {
+		if strings.TrimSpace(value) == "" {
+			return nil
+		}
+		return rule.Validate(ctx, value, data)
+	})
+}
+
+// RuleFactory creates a Rule from the parameters given in a tag string. For
+// example, for the tag "length=3 10", params is ["3", "10"]. It should
+// return an error if the parameters are invalid.
+type RuleFactory func(params ...string) (Rule, error)
+
+var (
+	rulesMu sync.RWMutex
+	rules   = map[string]RuleFactory{}
+)
+
+// RegisterRule registers rule under name so that it can be used in tag
+// strings. The rule does not accept any parameters. It replaces any rule
+// previously registered with the same name, including built in rules.
+// Names should be lower case words separated by underscores, e.g. "sku".
+func RegisterRule(name string, rule Rule) {
+	RegisterRuleFactory(name, func(params ...string) (Rule, error) {
+		if len(params) != 0 {
+			return nil, fmt.Errorf("forms: rule %s does not accept parameters", name)
+		}
+		return rule, nil
+	})
+}
+
+// RegisterRuleFactory is like RegisterRule but registers a RuleFactory, for
+// rules which accept parameters.
+func RegisterRuleFactory(name string, factory RuleFactory) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	rules[name] = factory
+}
+
+// LookupRule returns the rule registered under name, created with params.
+func LookupRule(name string, params ...string) (Rule, error) {
+	rulesMu.RLock()
+	factory, found := rules[name]
+	rulesMu.RUnlock()
+	if !found {
+		return nil, fmt.Errorf("forms: unknown rule %q", name)
+	}
+	return factory(params...)
+}
+
+// RuleNames returns the names of all registered rules in sorted order.
+func RuleNames() []string {
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+	names := make([]string, 0, len(rules))
+	for name := range rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseRules parses a tag string into a Rule. A tag string is a comma
+// separated list of rules which must all pass. Each rule is the name of a
+// registered rule, optionally followed by "=" and its parameters separated
+// by spaces. Alternatives may be separated by "|", in which case only one
+// of them has to pass, and a rule may be preceded by "!" to negate it. If
+// the list includes "optional", the other rules are only applied if the
+// value is not empty. For example:
+//
+//	"optional,slug|uuid,!in=admin root,max_length=40"
+func ParseRules(tag string) (Rule, error) {
+	all := []Rule{}
+	optional := false
+	for _, term := range strings.Split(tag, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		if term == "optional" {
+			optional = true
+			continue
+		}
+		alternatives := []Rule{}
+		for _, alt := range strings.Split(term, "|") {
+			rule, err := parseRule(strings.TrimSpace(alt))
+			if err != nil {
+				return nil, err
+			}
+			alternatives = append(alternatives, rule)
+		}
+		if len(alternatives) == 1 {
+			all = append(all, alternatives[0])
+		} else {
+			all = append(all, Or(alternatives...))
+		}
+	}
+	rule := And(all...)
+	if optional {
+		rule = Optional(rule)
+	}
+	return rule, nil
+}
+
+// parseRule parses a single rule, such as "!in=admin root".
+func parseRule(str string) (Rule, error) {
+	negate := strings.HasPrefix(str, "!")
+	str = strings.TrimPrefix(str, "!")
+	name, params := str, []string{}
+	if i := strings.Index(str, "="); i != -1 {
+		name, params = str[:i], strings.Fields(str[i+1:])
+	}
+	if name == "" {
+		return nil, fmt.Errorf("forms: missing rule name in %q", str)
+	}
+	rule, err := LookupRule(name, params...)
+	if err != nil {
+		return nil, err
+	}
+	if negate {
+		rule = Not(rule)
+	}
+	return rule, nil
+}
+
+// Check will add an error to the Validator if the first element of
+// data.Values[field] does not satisfy the rules in tag, as parsed by
+// ParseRules, e.g. Check("username", "required,slug,max_length=20"). At
+// most one error is added. Check panics if tag is invalid, so tags should
+// be constants.
+func (v *Validator) Check(field string, tag string) *ValidationResult {
+	return v.CheckContext(context.Background(), field, tag)
+}
+
+// CheckContext is like Check but passes ctx to the rules.
+func (v *Validator) CheckContext(ctx context.Context, field string, tag string) *ValidationResult {
+	rule, err := ParseRules(tag)
+	if err != nil {
+		panic(err)
+	}
+	return v.ApplyContext(ctx, field, rule)
+}
+
+// CheckStruct calls Check for each field of the struct s (or the struct s
+// points to) which has a "validate" tag. The field of the data which is
+// checked is the same one Bind would set, i.e. the one given by the "form"
+// tag, or the name of the struct field if it has no "form" tag. Fields of
+// embedded structs are checked too. It returns the ValidationResults for
+// any fields which failed. For example:
+//
+//	type Signup struct {
+//		Username string `form:"username" validate:"required,slug"`
+//		Website  string `form:"website" validate:"optional,url"`
+//	}
+//
+//	val.CheckStruct(Signup{})
+func (v *Validator) CheckStruct(s interface{}) []*ValidationResult {
+	typ := reflect.TypeOf(s)
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		panic("forms: CheckStruct requires a struct or a pointer to a struct")
+	}
+	results := []*ValidationResult{}
+	v.checkStructType(typ, &results)
+	return results
+}
+
+func (v *Validator) checkStructType(typ reflect.Type, results *[]*ValidationResult) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		key := field.Tag.Get("form")
+		if key == "-" {
+			continue
+		}
+		if field.Anonymous && key == "" && field.Type.Kind() == reflect.Struct {
+			v.checkStructType(field.Type, results)
+			continue
+		}
+		tag, found := field.Tag.Lookup("validate")
+		if !found || !field.IsExported() {
+			continue
+		}
+		if key == "" {
+			key = field.Name
+		}
+		if result := v.Check(key, tag); result != validationOk {
+			*results = append(*results, result)
+		}
+	}
+}
+
+// methodRule is a Rule which calls a validation method of a Validator.
+type methodRule struct {
+	name   string
+	params map[string]string
+	check  func(v *Validator, field string) *ValidationResult
+}
+
+// Validate runs r.check on a copy of data in which FieldPlaceholder holds
+// value, so that the message of any error refers to FieldPlaceholder.
+func (r *methodRule) Validate(ctx context.Context, value string, data *Data) error {
+	scratch := newData()
+	if data != nil {
+		for key, vals := range data.Values {
+			scratch.Values[key] = vals
+		}
+		scratch.Files = data.Files
+	}
+	scratch.Values[FieldPlaceholder] = []string{value}
+	val := scratch.Validator()
+	r.check(val, FieldPlaceholder)
+	if !val.HasErrors() {
+		return nil
+	}
+	result := val.results[0]
+	code := result.code
+	if code == "" {
+		code = r.name
+	}
+	return &RuleError{Code: code, Message: result.message, Params: r.params}
+}
+
+// registerMethod registers a built in rule which calls a validation method.
+// paramNames are the names of its parameters. If variadic is true, the
+// rule accepts any number of parameters, which are all named by
+// paramNames[0]. build converts the parameters to a function which calls
+// the method.
+func registerMethod(name string, paramNames []string, variadic bool, build func(params []string) (func(v *Validator, field string) *ValidationResult, error)) {
+	RegisterRuleFactory(name, func(params ...string) (Rule, error) {
+		if !variadic && len(params) != len(paramNames) {
+			return nil, fmt.Errorf("forms: rule %s requires %d parameters but got %d", name, len(paramNames), len(params))
+		}
+		check, err := build(params)
+		if err != nil {
+			return nil, fmt.Errorf("forms: invalid parameters for rule %s: %s", name, err)
+		}
+		named := map[string]string{}
+		if variadic {
+			if len(params) > 0 {
+				named[paramNames[0]] = strings.Join(params, " ")
+			}
+		} else {
+			for i, param := range params {
+				named[paramNames[i]] = param
+			}
+		}
+		return &methodRule{name: name, params: named, check: check}, nil
+	})
+}
+
+// registerSimpleMethod registers a built in rule without parameters.
+func registerSimpleMethod(name string, check func(v *Validator, field string) *ValidationResult) {
+	registerMethod(name, nil, false, func(params []string) (func(v *Validator, field string) *ValidationResult, error) {
+		return check, nil
+	})
+}
+
+// registerIntMethod registers a built in rule with a single integer
+// parameter.
+func registerIntMethod(name string, paramName string, method func(v *Validator, field string, n int) *ValidationResult) {
+	registerMethod(name, []string{paramName}, false, func(params []string) (func(v *Validator, field string) *ValidationResult, error) {
+		n, err := strconv.Atoi(params[0])
+		if err != nil {
+			return nil, err
+		}
+		return func(v *Validator, field string) *ValidationResult {
+			return method(v, field, n)
+		}, nil
+	})
+}
+
+// registerFloatMethod registers a built in rule with a single numeric
+// parameter.
+func registerFloatMethod(name string, paramName string, method func(v *Validator, field string, value float64) *ValidationResult) {
+	registerMethod(name, []string{paramName}, false, func(params []string) (func(v *Validator, field string) *ValidationResult, error) {
+		value, err := strconv.ParseFloat(params[0], 64)
+		if err != nil {
+			return nil, err
+		}
+		return func(v *Validator, field string) *ValidationResult {
+			return method(v, field, value)
+		}, nil
+	})
+}
+
+// registerStringsMethod registers a built in rule with any number of string
+// parameters.
+func registerStringsMethod(name string, paramName string, method func(v *Validator, field string, values ...string) *ValidationResult) {
+	registerMethod(name, []string{paramName}, true, func(params []string) (func(v *Validator, field string) *ValidationResult, error) {
+		return func(v *Validator, field string) *ValidationResult {
+			return method(v, field, params...)
+		}, nil
+	})
+}
+
+// registerFieldMethod registers a built in rule which compares the field
+// with another field, given as its parameter.
+func registerFieldMethod(name string, method func(v *Validator, field1 string, field2 string) *ValidationResult) {
+	registerMethod(name, []string{"field"}, false, func(params []string) (func(v *Validator, field string) *ValidationResult, error) {
+		return func(v *Validator, field string) *ValidationResult {
+			return method(v, field, params[0])
+		}, nil
+	})
+}
+
+func init() {
+	registerSimpleMethod("required", (*Validator).Require)
+	registerSimpleMethod("accepted", (*Validator).Accepted)
+	registerSimpleMethod("int", (*Validator).TypeInt)
+	registerSimpleMethod("int64", (*Validator).TypeInt64)
+	registerSimpleMethod("uint64", (*Validator).TypeUint64)
+	registerSimpleMethod("float", (*Validator).TypeFloat)
+	registerSimpleMethod("decimal", (*Validator).TypeDecimal)
+	registerSimpleMethod("bool", (*Validator).TypeBool)
+	registerSimpleMethod("duration", (*Validator).TypeDuration)
+	registerStringsMethod("date", "layouts", (*Validator).TypeDate)
+	registerIntMethod("min_length", "min", (*Validator).MinLength)
+	registerIntMethod("max_length", "max", (*Validator).MaxLength)
+	registerMethod("length", []string{"min", "max"}, false, func(params []string) (func(v *Validator, field string) *ValidationResult, error) {
+		min, err := strconv.Atoi(params[0])
+		if err != nil {
+			return nil, err
+		}
+		max, err := strconv.Atoi(params[1])
+		if err != nil {
+			return nil, err
+		}
+		return func(v *Validator, field string) *ValidationResult {
+			return v.LengthRange(field, min, max)
+		}, nil
+	})
+	registerFloatMethod("gt", "value", (*Validator).Greater)
+	registerFloatMethod("gte", "value", (*Validator).GreaterOrEqual)
+	registerFloatMethod("lt", "value", (*Validator).Less)
+	registerFloatMethod("lte", "value", (*Validator).LessOrEqual)
+	registerStringsMethod("in", "values", (*Validator).In)
+	registerStringsMethod("not_in", "values", (*Validator).NotIn)
+	registerFieldMethod("equal", func(v *Validator, field1 string, field2 string) *ValidationResult {
+		// unlike Equal, associate the error with the field being checked
+		return v.Compare(field1, OpEqual, field2, TargetFirst)
+	})
+	registerFieldMethod("not_equal", (*Validator).NotEqual)
+	registerFieldMethod("gt_field", (*Validator).GreaterThanField)
+	registerFieldMethod("lt_field", (*Validator).LessThanField)
+	registerSimpleMethod("email", func(v *Validator, field string) *ValidationResult {
+		return v.Email(field, nil)
+	})
+	registerStringsMethod("url", "schemes", (*Validator).URL)
+	registerSimpleMethod("hostname", (*Validator).Hostname)
+	registerSimpleMethod("ip", (*Validator).IP)
+	registerSimpleMethod("ipv4", (*Validator).IPv4)
+	registerSimpleMethod("ipv6", (*Validator).IPv6)
+	registerSimpleMethod("cidr", (*Validator).CIDR)
+	registerSimpleMethod("mac", (*Validator).MACAddress)
+	registerMethod("uuid", []string{"versions"}, true, func(params []string) (func(v *Validator, field string) *ValidationResult, error) {
+		versions := make([]int, len(params))
+		for i, param := range params {
+			version, err := strconv.Atoi(param)
+			if err != nil {
+				return nil, err
+			}
+			versions[i] = version
+		}
+		return func(v *Validator, field string) *ValidationResult {
+			return v.UUID(field, versions...)
+		}, nil
+	})
+	registerSimpleMethod("ulid", (*Validator).ULID)
+	registerSimpleMethod("hex_color", (*Validator).HexColor)
+	registerSimpleMethod("base64", (*Validator).Base64)
+	registerSimpleMethod("base64url", (*Validator).Base64URL)
+	registerSimpleMethod("semver", (*Validator).SemVer)
+	registerSimpleMethod("slug", (*Validator).Slug)
+	registerSimpleMethod("luhn", (*Validator).Luhn)
+	registerSimpleMethod("card_number", (*Validator).CardNumber)
+	registerSimpleMethod("iban", (*Validator).IBAN)
+	registerSimpleMethod("isbn", (*Validator).ISBN)
+	registerSimpleMethod("isbn10", (*Validator).ISBN10)
+	registerSimpleMethod("isbn13", (*Validator).ISBN13)
+	registerSimpleMethod("e164", (*Validator).E164)
+	registerSimpleMethod("password", func(v *Validator, field string) *ValidationResult {
+		return v.PasswordStrength(field, nil)
+	})
+}
@@ -0,0 +1,214 @@
+// Copyright 2015 Alex Browne.  All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package forms
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var testSKURegex = regexp.MustCompile(`^[A-Z]{3}-\d{4}$`)
+
+func init() {
+	RegisterRule("test_sku", RuleFunc(func(ctx context.Context, value string, data *Data) error {
+		if !testSKURegex.MatchString(value) {
+			return &RuleError{Code: "sku", Message: "{field} must be a SKU such as ABC-1234."}
+		}
+		return nil
+	}))
+	RegisterRuleFactory("test_prefix", func(params ...string) (Rule, error) {
+		if len(params) != 1 {
+			return nil, errors.New("test_prefix requires a prefix")
+		}
+		return RuleFunc(func(ctx context.Context, value string, data *Data) error {
+			if !strings.HasPrefix(value, params[0]) {
+				return errors.New("{field} must start with " + params[0] + ".")
+			}
+			return nil
+		}), nil
+	})
+}
+
+func TestApply(t *testing.T) {
+	data := newData()
+	data.Add("sku", "ABC-1234")
+	data.Add("bad_sku", "abc")
+	sku, err := LookupRule("test_sku")
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefix, err := LookupRule("test_prefix", "AB")
+	if err != nil {
+		t.Fatal(err)
+	}
+	val := data.Validator()
+	val.Apply("sku", sku)
+	val.Apply("sku", And(sku, prefix))
+	val.Apply("bad_sku", Or(sku, Not(prefix)))
+	if val.HasErrors() {
+		t.Errorf("Expected no errors but got errors: %v", val.Messages())
+	}
+
+	val.Apply("bad_sku", sku)
+	val.Apply("bad_sku", And(prefix, sku))
+	val.Apply("sku", Not(prefix)).Message("sku cannot start with AB.")
+	expectedMessages := []string{
+		"bad_sku must be a SKU such as ABC-1234.",
+		"bad_sku must start with AB.",
+		"sku cannot start with AB.",
+	}
+	if !reflect.DeepEqual(val.Messages(), expectedMessages) {
+		t.Errorf("Expected messages %v but got %v.", expectedMessages, val.Messages())
+	}
+	expectedCodes := []string{"sku", "", "not"}
+	if !reflect.DeepEqual(val.Codes(), expectedCodes) {
+		t.Errorf("Expected codes %v but got %v.", expectedCodes, val.Codes())
+	}
+
+	if _, err := LookupRule("test_sku", "extra"); err == nil {
+		t.Error("Expected an error for unexpected parameters but got none.")
+	}
+	if _, err := LookupRule("no_such_rule"); err == nil {
+		t.Error("Expected an error for an unknown rule but got none.")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	data := newData()
+	data.Add("username", "bob-smith")
+	data.Add("reserved", "admin")
+	data.Add("long", "a-very-long-username")
+	data.Add("id", "01ARZ3NDEKTSV4RRFFQ69G5FAV")
+	data.Add("sku", "XYZ-0001")
+	data.Add("quantity", "3")
+	data.Add("password", "hunter2")
+	data.Add("confirm", "hunter3")
+	data.Add("empty", "")
+
+	val := data.Validator()
+	val.Check("username", "required,slug,!in=admin root,max_length=12")
+	val.Check("id", "uuid|ulid")
+	val.Check("sku", "test_sku")
+	val.Check("quantity", "int,gte=1,lte=10")
+	val.Check("empty", "optional,email")
+	val.Check("missing", "optional,slug")
+	if val.HasErrors() {
+		t.Errorf("Expected no errors but got errors: %v", val.Messages())
+	}
+
+	val.Check("reserved", "required,slug,!in=admin root")
+	val.Check("long", "slug,max_length=12")
+	val.Check("username", "uuid|ulid")
+	val.Check("quantity", "in=1 2")
+	val.Check("confirm", "equal=password")
+	val.Check("empty", "required,email")
+	expectedMessages := []string{
+		"reserved is invalid.",
+		"long cannot be more than 12 characters long.",
+		"username must be a valid UUID.",
+		"quantity must be one of 1 or 2.",
+		"confirm and password must match.",
+		"empty is required.",
+	}
+	if !reflect.DeepEqual(val.Messages(), expectedMessages) {
+		t.Errorf("Expected messages %v but got %v.", expectedMessages, val.Messages())
+	}
+	expectedCodes := []string{"not", "max_length", "uuid", "in", "equal", "required"}
+	if !reflect.DeepEqual(val.Codes(), expectedCodes) {
+		t.Errorf("Expected codes %v but got %v.", expectedCodes, val.Codes())
+	}
+	expectedFields := []string{"reserved", "long", "username", "quantity", "confirm", "empty"}
+	if !reflect.DeepEqual(val.Fields(), expectedFields) {
+		t.Errorf("Expected fields %v but got %v.", expectedFields, val.Fields())
+	}
+}
+
+func TestRuleParams(t *testing.T) {
+	rule, err := LookupRule("length", "3", "10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rule.Validate(context.Background(), "ab", newData())
+	ruleErr, ok := err.(*RuleError)
+	if !ok {
+		t.Fatalf("Expected a *RuleError but got %T: %v", err, err)
+	}
+	expected := map[string]string{"min": "3", "max": "10"}
+	if !reflect.DeepEqual(ruleErr.Params, expected) {
+		t.Errorf("Expected params %v but got %v.", expected, ruleErr.Params)
+	}
+	if !strings.HasPrefix(ruleErr.Message, FieldPlaceholder) {
+		t.Errorf("Expected the message to start with %s but got %q.", FieldPlaceholder, ruleErr.Message)
+	}
+}
+
+func TestCheckParams(t *testing.T) {
+	data := newData()
+	data.Add("username", "ab")
+	data.Add("sku", "x")
+	val := data.Validator()
+	val.Check("username", "required,length=3 10")
+	val.Apply("sku", RuleFunc(func(ctx context.Context, value string, data *Data) error {
+		return errors.New("{field} is not a valid SKU.")
+	}))
+	expected := []map[string]string{{"min": "3", "max": "10"}, nil}
+	if !reflect.DeepEqual(val.ParamsList(), expected) {
+		t.Errorf("Expected params %v but got %v.", expected, val.ParamsList())
+	}
+	expectedCodes := []string{"length", ""}
+	if !reflect.DeepEqual(val.Codes(), expectedCodes) {
+		t.Errorf("Expected codes %v but got %v.", expectedCodes, val.Codes())
+	}
+}
+
+func TestParseRulesErrors(t *testing.T) {
+	for _, tag := range []string{"no_such_rule", "slug,=3", "min_length", "min_length=abc", "length=1", "uuid=four"} {
+		if _, err := ParseRules(tag); err == nil {
+			t.Errorf("Expected an error for %q but got none.", tag)
+		}
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected Check to panic for an invalid tag but it did not.")
+		}
+	}()
+	newData().Validator().Check("field", "no_such_rule")
+}
+
+func TestCheckStruct(t *testing.T) {
+	type contact struct {
+		Email string `form:"email" validate:"required,email"`
+	}
+	type signup struct {
+		contact
+		Username string `form:"username" validate:"required,slug"`
+		Website  string `form:"website" validate:"optional,url"`
+		SKU      string `validate:"test_sku"`
+		Ignored  string `form:"-" validate:"required"`
+		Notes    string `form:"notes"`
+	}
+	data := newData()
+	data.Add("email", "bob@example.com")
+	data.Add("username", "Bob Smith")
+	data.Add("SKU", "ABC-1234")
+	val := data.Validator()
+	results := val.CheckStruct(&signup{})
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 result but got %d.", len(results))
+	}
+	expected := map[string][]string{
+		"username": {"username must be a valid slug."},
+	}
+	if !reflect.DeepEqual(val.ErrorMap(), expected) {
+		t.Errorf("Expected errors %v but got %v.", expected, val.ErrorMap())
+	}
+	if !reflect.DeepEqual(RuleNames()[:3], []string{"accepted", "base64", "base64url"}) {
+		t.Errorf("Unexpected rule names: %v", RuleNames())
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2015 Alex Browne.  All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package forms
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ScanResult is the result of scanning a file with a FileScanner.
+type ScanResult struct {
+	// Infected is true iff the scanner found malicious content.
+	Infected bool
+	// Signature is the name of the malicious content which was found,
+	// if any (e.g. "Eicar-Signature").
+	Signature string
+}
+
+// FileScanner scans the contents of files for viruses or other unwanted
+// content.
+type FileScanner interface {
+	// Scan reads all of r and reports whether it is infected. An error
+	// means the content could not be scanned, not that it is infected.
+	// A nil result without an error is also treated as a failure to
+	// scan, never as clean.
+	Scan(r io.Reader) (*ScanResult, error)
+}
+
+// ScannerFunc is an adapter which allows an ordinary function to be used as
+// a FileScanner. It is especially useful in tests.
+type ScannerFunc func(r io.Reader) (*ScanResult, error)
+
+// Scan calls f(r).
+func (f ScannerFunc) Scan(r io.Reader) (*ScanResult, error) {
+	return f(r)
+}
+
+// NopScanner is a FileScanner which reports every file as clean without
+// reading it.
+var NopScanner FileScanner = ScannerFunc(func(r io.Reader) (*ScanResult, error) {
+	return &ScanResult{}, nil
+})
+
+// DefaultClamdChunkSize is the default size of the chunks in which a
+// ClamdScanner sends files to clamd.
+const DefaultClamdChunkSize = 32 * 1024
+
+// ClamdScanner is a FileScanner which sends files to a clamd (ClamAV) daemon
+// using the INSTREAM command. A new connection is made for each scan.
+type ClamdScanner struct {
+	// Network is "unix" or "tcp".
+	Network string
+	// Address is the path of the unix socket or the host:port of clamd.
+	Address string
+	// Timeout, if not 0, is the maximum amount of time a scan may take,
+	// including connecting.
+	Timeout time.Duration
+	// ChunkSize is the size of the chunks in which a file is sent. If it
+	// is 0, DefaultClamdChunkSize is used. It must not be more than the
+	// StreamMaxLength configured for clamd.
+	ChunkSize int
+}
+
+// NewClamdScanner returns a ClamdScanner which connects to clamd at address
+// over network (e.g. "unix", "/var/run/clamav/clamd.ctl" or "tcp",
+// "127.0.0.1:3310") with a timeout of one minute.
+func NewClamdScanner(network string, address string) *ClamdScanner {
+	return &ClamdScanner{
+		Network: network,
+		Address: address,
+		Timeout: time.Minute,
+	}
+}
+
+// Scan sends the contents of r to clamd and returns the result.
+func (s *ClamdScanner) Scan(r io.Reader) (*ScanResult, error) {
+	dialer := &net.Dialer{Timeout: s.Timeout}
+	conn, err := dialer.Dial(s.Network, s.Address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if s.Timeout != 0 {
+		if err := conn.SetDeadline(time.Now().Add(s.Timeout)); err != nil {
+			return nil, err
+		}
+	}
+	// The "z" prefix means that the command and response are
+	// terminated by a null character.
+	if _, err := io.WriteString(conn, "zINSTREAM\x00"); err != nil {
+		return nil, err
+	}
+	chunkSize := s.ChunkSize
+	if chunkSize == 0 {
+		chunkSize = DefaultClamdChunkSize
+	}
+	buf := make([]byte, 4+chunkSize)
+	for {
+		n, readErr := io.ReadFull(r, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			if _, err := conn.Write(buf[:4+n]); err != nil {
+				return nil, err
+			}
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		} else if readErr != nil {
+			return nil, readErr
+		}
+	}
+	// A chunk with a length of zero marks the end of the stream.
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return nil, err
+	}
+	response, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return parseClamdResponse(strings.TrimRight(response, "\x00\n"))
+}
+
+// parseClamdResponse parses a response to the INSTREAM command, which looks
+// like "stream: OK", "stream: Eicar-Signature FOUND", or "<reason> ERROR".
+func parseClamdResponse(response string) (*ScanResult, error) {
+	result := strings.TrimSpace(response)
+	if i := strings.Index(result, ": "); i != -1 {
+		result = result[i+2:]
+	}
+	switch {
+	case result == "OK":
+		return &ScanResult{}, nil
+	case strings.HasSuffix(result, " FOUND"):
+		return &ScanResult{
+			Infected:  true,
+			Signature: strings.TrimSuffix(result, " FOUND"),
+		}, nil
+	default:
+		return nil, fmt.Errorf("forms: clamd could not scan file: %s", response)
+	}
+}
+
+// ScanFile will add an error to the Validator if scanner reports that the
+// file identified by field is infected or if the file could not be scanned.
+// If the file does not exist, it does not add an error to the Validator.
+func (v *Validator) ScanFile(field string, scanner FileScanner) *ValidationResult {
+	if !v.data.FileExists(field) {
+		return validationOk
+	}
+	file, err := v.data.GetFile(field).Open()
+	if err != nil {
+		return v.AddError(field, "Could not read file.")
+	}
+	defer file.Close()
+	result, err := scanner.Scan(file)
+	if err != nil || result == nil {
+		msg := fmt.Sprintf("%s could not be scanned.", field)
+		return v.AddError(field, msg)
+	}
+	if result.Infected {
+		msg := fmt.Sprintf("%s appears to contain malicious content.", field)
+		return v.AddError(field, msg)
+	}
+	return validationOk
+}
+
+// ScanFiles calls ScanFile for every file in data.Files, in order of their
+// keys. It returns the ValidationResults for any files which were infected
+// or could not be scanned, so that their fields or messages can be changed.
+func (v *Validator) ScanFiles(scanner FileScanner) []*ValidationResult {
+	keys := []string{}
+	for key := range v.data.Files {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	results := []*ValidationResult{}
+	for _, key := range keys {
+		if result := v.ScanFile(key, scanner); result != validationOk {
+			results = append(results, result)
+		}
+	}
+	return results
+}
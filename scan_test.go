@@ -0,0 +1,181 @@
+// Copyright 2015 Alex Browne.  All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package forms
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testVirusMarker = "EICAR-STANDARD-ANTIVIRUS-TEST-FILE"
+
+// serveFakeClamd accepts connections on listener and responds to INSTREAM
+// commands like clamd would, reporting any stream which contains
+// testVirusMarker as infected.
+func serveFakeClamd(t *testing.T, listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func(conn net.Conn) {
+			defer conn.Close()
+			reader := bufio.NewReader(conn)
+			command, err := reader.ReadString(0)
+			if err != nil || command != "zINSTREAM\x00" {
+				io.WriteString(conn, "UNKNOWN COMMAND\x00")
+				return
+			}
+			content := bytes.NewBuffer([]byte{})
+			for {
+				var size uint32
+				if err := binary.Read(reader, binary.BigEndian, &size); err != nil {
+					t.Error(err)
+					return
+				}
+				if size == 0 {
+					break
+				}
+				if _, err := io.CopyN(content, reader, int64(size)); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+			if strings.Contains(content.String(), testVirusMarker) {
+				io.WriteString(conn, "stream: Eicar-Signature FOUND\x00")
+			} else {
+				io.WriteString(conn, "stream: OK\x00")
+			}
+		}(conn)
+	}
+}
+
+func testClamdScanner(t *testing.T, scanner *ClamdScanner) {
+	// use a small chunk size so the file is sent in several chunks
+	scanner.ChunkSize = 7
+	result, err := scanner.Scan(strings.NewReader("This file is perfectly safe."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Infected {
+		t.Errorf("Expected a clean result but got %+v.", result)
+	}
+	result, err = scanner.Scan(strings.NewReader("X5O!P%@AP[4\\PZX54(P^)7CC)7}$" + testVirusMarker + "!$H+H*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &ScanResult{Infected: true, Signature: "Eicar-Signature"}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %+v but got %+v.", expected, result)
+	}
+}
+
+func TestClamdScannerUnix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "forms-clamd-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "clamd.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("Could not listen on unix socket: %s", err)
+	}
+	defer listener.Close()
+	go serveFakeClamd(t, listener)
+	testClamdScanner(t, NewClamdScanner("unix", socket))
+}
+
+func TestClamdScannerTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("Could not listen on tcp: %s", err)
+	}
+	defer listener.Close()
+	go serveFakeClamd(t, listener)
+	testClamdScanner(t, NewClamdScanner("tcp", listener.Addr().String()))
+}
+
+func TestParseClamdResponse(t *testing.T) {
+	if _, err := parseClamdResponse("INSTREAM size limit exceeded. ERROR"); err == nil {
+		t.Error("Expected an error for an ERROR response but got none.")
+	}
+	result, err := parseClamdResponse("stream: Win.Test.EICAR_HDB-1 FOUND")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Infected || result.Signature != "Win.Test.EICAR_HDB-1" {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+}
+
+func TestScanFiles(t *testing.T) {
+	data := newData()
+	contents := map[string]string{
+		"clean":    "Hello!",
+		"infected": "Hello! " + testVirusMarker,
+		"broken":   "break the scanner",
+	}
+	for key, content := range contents {
+		fileHeader, err := createTestFileHeader(key+".txt", []byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data.AddFile(key, fileHeader)
+	}
+	scanner := ScannerFunc(func(r io.Reader) (*ScanResult, error) {
+		content, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		if strings.Contains(string(content), "break") {
+			return nil, errors.New("scanner broke")
+		}
+		return &ScanResult{Infected: strings.Contains(string(content), testVirusMarker)}, nil
+	})
+
+	val := data.Validator()
+	val.ScanFile("clean", scanner)
+	val.ScanFile("missing", scanner)
+	if val.HasErrors() {
+		t.Errorf("Expected no errors but got errors: %v", val.Messages())
+	}
+	if results := data.Validator().ScanFiles(NopScanner); len(results) != 0 {
+		t.Errorf("Expected no results from NopScanner but got %d.", len(results))
+	}
+
+	val = data.Validator()
+	results := val.ScanFiles(scanner)
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results but got %d.", len(results))
+	}
+	expected := []string{
+		"broken could not be scanned.",
+		"infected appears to contain malicious content.",
+	}
+	if !reflect.DeepEqual(val.Messages(), expected) {
+		t.Errorf("Expected messages %v but got %v.", expected, val.Messages())
+	}
+
+	// a nil result without an error is a failure to scan
+	val = data.Validator()
+	val.ScanFile("clean", ScannerFunc(func(r io.Reader) (*ScanResult, error) {
+		return nil, nil
+	}))
+	expected = []string{"clean could not be scanned."}
+	if !reflect.DeepEqual(val.Messages(), expected) {
+		t.Errorf("Expected messages %v but got %v.", expected, val.Messages())
+	}
+}
@@ -0,0 +1,292 @@
+// Copyright 2015 Alex Browne.  All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package forms
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// sniffLen is the number of bytes read from the start of a file in order
+// to detect its content type. It matches the number of bytes considered by
+// http.DetectContentType.
+const sniffLen = 512
+
+// fileSignature identifies a content type by the bytes found at the start
+// of a file. If extra is not nil, it must also be found at extraOffset. If
+// check is not nil, it must also return true for the start of the file.
+type fileSignature struct {
+	magic       []byte
+	extraOffset int
+	extra       []byte
+	check       func(head []byte) bool
+	contentType string
+}
+
+// fileSignatures are checked in order before falling back to
+// http.DetectContentType.
+var fileSignatures = []fileSignature{
+	{magic: []byte("%PDF-"), contentType: "application/pdf"},
+	{magic: []byte("PK\x03\x04"), contentType: "application/zip"},
+	{magic: []byte("PK\x05\x06"), contentType: "application/zip"},
+	{magic: []byte("\x89PNG\r\n\x1a\n"), contentType: "image/png"},
+	{magic: []byte("\xff\xd8\xff"), contentType: "image/jpeg"},
+	{magic: []byte("GIF87a"), contentType: "image/gif"},
+	{magic: []byte("GIF89a"), contentType: "image/gif"},
+	{magic: []byte("RIFF"), extraOffset: 8, extra: []byte("WEBP"), contentType: "image/webp"},
+	{magic: []byte("\x1f\x8b"), contentType: "application/gzip"},
+	{magic: []byte("Rar!\x1a\x07"), contentType: "application/vnd.rar"},
+	{magic: []byte("7z\xbc\xaf\x27\x1c"), contentType: "application/x-7z-compressed"},
+	{magic: []byte("MZ"), check: hasPEHeader, contentType: "application/vnd.microsoft.portable-executable"},
+	{magic: []byte("\x7fELF"), contentType: "application/x-elf"},
+}
+
+// contentTypeAliases maps non-standard names for a content type to the
+// name used by fileSignatures.
+var contentTypeAliases = map[string]string{
+	"application/x-gzip":           "application/gzip",
+	"application/x-zip-compressed": "application/zip",
+	"application/x-zip":            "application/zip",
+	"application/x-pdf":            "application/pdf",
+	"application/x-rar-compressed": "application/vnd.rar",
+	"image/jpg":                    "image/jpeg",
+	"image/pjpeg":                  "image/jpeg",
+	"image/x-png":                  "image/png",
+}
+
+// unknownContentType is the type reported when the content of a file could
+// not be identified.
+const unknownContentType = "application/octet-stream"
+
+// DetectFileContentType returns the content type of the file identified by
+// header, based on the actual contents of the file rather than its name or
+// the Content-Type declared by the client. The result never includes
+// parameters such as charset. If the type cannot be determined, it returns
+// "application/octet-stream".
+func DetectFileContentType(header *multipart.FileHeader) (string, error) {
+	head, err := peekFile(header, sniffLen)
+	if err != nil {
+		return "", err
+	}
+	return sniffContentType(head), nil
+}
+
+// GetFileContentType returns the sniffed content type of the file associated
+// with key. If there is no file associated with key, it returns the empty
+// string (not an error). See DetectFileContentType.
+func (d Data) GetFileContentType(key string) (string, error) {
+	header, found := d.Files[key]
+	if !found {
+		return "", nil
+	}
+	return DetectFileContentType(header)
+}
+
+// peekFile returns up to the first n bytes of the file identified by header.
+func peekFile(header *multipart.FileHeader, n int) ([]byte, error) {
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	buf := make([]byte, n)
+	got, err := io.ReadFull(file, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	return buf[:got], nil
+}
+
+func sniffContentType(head []byte) string {
+	for _, sig := range fileSignatures {
+		if !bytes.HasPrefix(head, sig.magic) {
+			continue
+		}
+		if sig.extra != nil {
+			if len(head) < sig.extraOffset+len(sig.extra) {
+				continue
+			}
+			if !bytes.Equal(head[sig.extraOffset:sig.extraOffset+len(sig.extra)], sig.extra) {
+				continue
+			}
+		}
+		if sig.check != nil && !sig.check(head) {
+			continue
+		}
+		return sig.contentType
+	}
+	return normalizeContentType(http.DetectContentType(head))
+}
+
+// hasPEHeader returns true iff head, which starts with an MS-DOS header,
+// contains the "PE\x00\x00" signature at the offset stored at 0x3C. Plain
+// text which happens to start with "MZ" does not.
+func hasPEHeader(head []byte) bool {
+	if len(head) < 0x40 {
+		return false
+	}
+	offset := int(binary.LittleEndian.Uint32(head[0x3C:0x40]))
+	return offset >= 0x40 && offset <= len(head)-4 && string(head[offset:offset+4]) == "PE\x00\x00"
+}
+
+// normalizeContentType strips any parameters from contentType, converts it
+// to lowercase, and resolves known aliases. It returns the empty string if
+// contentType cannot be parsed.
+func normalizeContentType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	if alias, found := contentTypeAliases[mediaType]; found {
+		return alias
+	}
+	return mediaType
+}
+
+// isSniffable returns true iff contentType is one which sniffContentType
+// is able to identify. If a file claims to be one of these types but is
+// sniffed as unknown, the claim is false.
+func isSniffable(contentType string) bool {
+	for _, sig := range fileSignatures {
+		if sig.contentType == contentType {
+			return true
+		}
+	}
+	switch contentType {
+	case "image/bmp", "image/x-icon", "audio/mpeg", "audio/wave", "video/webm", "video/mp4", "application/ogg":
+		return true
+	}
+	return false
+}
+
+// isTextContentType returns true iff contentType describes a text-based
+// format which may be sniffed as "text/plain".
+func isTextContentType(contentType string) bool {
+	if strings.HasPrefix(contentType, "text/") {
+		return true
+	}
+	if strings.HasSuffix(contentType, "+json") || strings.HasSuffix(contentType, "+xml") {
+		return true
+	}
+	switch contentType {
+	case "application/json", "application/xml", "application/javascript":
+		return true
+	}
+	return false
+}
+
+// isZipContentType returns true iff contentType describes a format which
+// is stored as a zip archive (e.g. docx or epub).
+func isZipContentType(contentType string) bool {
+	return strings.HasSuffix(contentType, "+zip") ||
+		strings.HasPrefix(contentType, "application/vnd.openxmlformats-officedocument.") ||
+		strings.HasPrefix(contentType, "application/vnd.oasis.opendocument.") ||
+		contentType == "application/java-archive"
+}
+
+// contentTypesMatch returns true iff a file which claims to be of type
+// claimed could reasonably have been sniffed as type sniffed. Both types
+// should already be normalized.
+func contentTypesMatch(claimed string, sniffed string) bool {
+	if claimed == sniffed {
+		return true
+	}
+	switch sniffed {
+	case unknownContentType:
+		return !isSniffable(claimed)
+	case "text/plain":
+		return isTextContentType(claimed)
+	case "text/xml":
+		return claimed == "application/xml" || strings.HasSuffix(claimed, "+xml")
+	case "application/zip":
+		return isZipContentType(claimed)
+	}
+	return false
+}
+
+// extContentType returns the normalized content type associated with the
+// extension of filename, or the empty string if it is not known.
+func extContentType(filename string) string {
+	contentType := normalizeContentType(mime.TypeByExtension(filepath.Ext(filename)))
+	if contentType == unknownContentType {
+		return ""
+	}
+	return contentType
+}
+
+// declaredContentType returns the normalized Content-Type which the client
+// declared for the file identified by header, or the empty string if it
+// did not declare a specific type.
+func declaredContentType(header *multipart.FileHeader) string {
+	contentType := normalizeContentType(header.Header.Get("Content-Type"))
+	if contentType == unknownContentType {
+		return ""
+	}
+	return contentType
+}
+
+// AcceptContentTypes will add an error to the Validator if the sniffed
+// content type of the file identified by field is not in types. Unlike
+// AcceptFileExts, the type is determined by looking at the contents of the
+// file, so it cannot be spoofed by renaming the file. types may include
+// wildcards of the form "image/*". If the file does not exist, it does not
+// add an error to the Validator.
+func (v *Validator) AcceptContentTypes(field string, types ...string) *ValidationResult {
+	if !v.data.FileExists(field) {
+		return validationOk
+	}
+	sniffed, err := DetectFileContentType(v.data.GetFile(field))
+	if err != nil {
+		return v.AddError(field, "Could not read file.")
+	}
+	for _, typ := range types {
+		if strings.HasSuffix(typ, "/*") {
+			if strings.HasPrefix(sniffed, strings.ToLower(typ[:len(typ)-1])) {
+				return validationOk
+			}
+		} else if normalizeContentType(typ) == sniffed {
+			return validationOk
+		}
+	}
+	return v.addContentTypeError(field, sniffed, types...)
+}
+
+func (v *Validator) addContentTypeError(field string, gotType string, allowedTypes ...string) *ValidationResult {
+	msg := fmt.Sprintf("The file type %s is not allowed. Allowed types include: %s", gotType, humanList(allowedTypes, "and"))
+	return v.AddError(field, msg)
+}
+
+// MatchContentType will add an error to the Validator if the sniffed content
+// type of the file identified by field does not match the type implied by
+// its file extension or the Content-Type declared by the client for the
+// file. A generic declared type of "application/octet-stream" and unknown
+// extensions are ignored. If the file does not exist, it does not add an
+// error to the Validator.
+func (v *Validator) MatchContentType(field string) *ValidationResult {
+	if !v.data.FileExists(field) {
+		return validationOk
+	}
+	header := v.data.GetFile(field)
+	sniffed, err := DetectFileContentType(header)
+	if err != nil {
+		return v.AddError(field, "Could not read file.")
+	}
+	if extType := extContentType(header.Filename); extType != "" && !contentTypesMatch(extType, sniffed) {
+		msg := fmt.Sprintf("The contents of %s (%s) do not match its file extension %s.", field, sniffed, filepath.Ext(header.Filename))
+		return v.AddError(field, msg)
+	}
+	if declared := declaredContentType(header); declared != "" && !contentTypesMatch(declared, sniffed) {
+		msg := fmt.Sprintf("The contents of %s (%s) do not match its declared type %s.", field, sniffed, declared)
+		return v.AddError(field, msg)
+	}
+	return validationOk
+}
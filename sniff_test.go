@@ -0,0 +1,155 @@
+// Copyright 2015 Alex Browne.  All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package forms
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+var (
+	testPNGBytes  = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR")
+	testPDFBytes  = []byte("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	testExeBytes  = []byte("MZ\x90\x00\x03\x00\x00\x00\x04\x00" + strings.Repeat("\x00", 0x32) + "\x40\x00\x00\x00PE\x00\x00\x4c\x01")
+	testWebPBytes = []byte("RIFF\x24\x00\x00\x00WEBPVP8 ")
+)
+
+func TestDetectFileContentType(t *testing.T) {
+	table := []struct {
+		content  []byte
+		expected string
+	}{
+		{testPNGBytes, "image/png"},
+		{testPDFBytes, "application/pdf"},
+		{testExeBytes, "application/vnd.microsoft.portable-executable"},
+		{testWebPBytes, "image/webp"},
+		{[]byte("GIF89a\x01\x00\x01\x00"), "image/gif"},
+		{[]byte("\xff\xd8\xff\xe0\x00\x10JFIF"), "image/jpeg"},
+		{[]byte("PK\x03\x04\x14\x00"), "application/zip"},
+		{[]byte("Hello!"), "text/plain"},
+		// starts with "MZ" but has no PE header
+		{[]byte("MZ,name,value\n1,foo,bar\n"), "text/plain"},
+		{[]byte("MZ\x90\x00\x03\x00\x00\x00\x04\x00"), "application/octet-stream"},
+		{[]byte{0x00, 0x01, 0x02, 0x03}, "application/octet-stream"},
+	}
+	for i, test := range table {
+		fileHeader, err := createTestFileHeader("file", test.content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := DetectFileContentType(fileHeader)
+		if err != nil {
+			t.Error(err)
+		} else if got != test.expected {
+			t.Errorf("Content type for case %d was incorrect. Expected %s, but got %s.", i, test.expected, got)
+		}
+	}
+}
+
+func TestAcceptContentTypes(t *testing.T) {
+	data := newData()
+	png, err := createTestFileHeader("image.png", testPNGBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data.AddFile("png", png)
+	exe, err := createTestFileHeader("image.png", testExeBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data.AddFile("exe", exe)
+
+	val := data.Validator()
+	val.AcceptContentTypes("png", "image/png")
+	val.AcceptContentTypes("png", "image/*")
+	val.AcceptContentTypes("png", "application/pdf", "IMAGE/PNG")
+	val.AcceptContentTypes("missing", "image/png")
+	if val.HasErrors() {
+		t.Errorf("Expected no errors but got errors: %v", val.Messages())
+	}
+
+	val.AcceptContentTypes("exe", "image/png", "image/jpeg", "image/gif")
+	if len(val.Messages()) != 1 {
+		t.Fatalf("Expected 1 validation error but got %d.", len(val.Messages()))
+	}
+	expectedMsg := "Allowed types include: image/png, image/jpeg, and image/gif"
+	if msg := val.Messages()[0]; !strings.Contains(msg, expectedMsg) {
+		t.Errorf(`Expected error to contain "%s" but got "%s"`, expectedMsg, msg)
+	}
+}
+
+func TestMatchContentType(t *testing.T) {
+	table := []struct {
+		filename    string
+		contentType string
+		content     []byte
+		expectError bool
+	}{
+		{"image.png", "image/png", testPNGBytes, false},
+		{"image.png", "application/octet-stream", testPNGBytes, false},
+		{"doc.pdf", "application/pdf", testPDFBytes, false},
+		{"notes.txt", "text/plain", []byte("Hello!"), false},
+		{"data.csv", "text/csv", []byte("a,b,c\n1,2,3\n"), false},
+		{"program", "application/octet-stream", testExeBytes, false},
+		// renamed executable
+		{"image.png", "application/octet-stream", testExeBytes, true},
+		// declared type does not match
+		{"image", "image/png", testExeBytes, true},
+		// extension does not match
+		{"image.pdf", "application/pdf", testPNGBytes, true},
+		// claims to be a png but has no recognizable content
+		{"image.png", "image/png", []byte{0x00, 0x01, 0x02}, true},
+	}
+	for i, test := range table {
+		fileHeader, err := createTestFileHeaderWithType(test.filename, test.contentType, test.content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := newData()
+		data.AddFile("file", fileHeader)
+		val := data.Validator()
+		val.MatchContentType("file")
+		if test.expectError && !val.HasErrors() {
+			t.Errorf("Expected an error for case %d but got none.", i)
+		} else if !test.expectError && val.HasErrors() {
+			t.Errorf("Expected no errors for case %d but got errors: %v", i, val.Messages())
+		}
+	}
+}
+
+// createTestFileHeaderWithType is like createTestFileHeader but allows the
+// Content-Type of the file part to be specified.
+func createTestFileHeaderWithType(filename string, contentType string, content []byte) (*multipart.FileHeader, error) {
+	body := bytes.NewBuffer([]byte{})
+	partWriter := multipart.NewWriter(body)
+	partHeader := textproto.MIMEHeader{}
+	partHeader.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="%s"`, filename))
+	partHeader.Set("Content-Type", contentType)
+	fileWriter, err := partWriter.CreatePart(partHeader)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fileWriter.Write(content); err != nil {
+		return nil, err
+	}
+	if err := partWriter.Close(); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", "/", body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "multipart/form-data; boundary="+partWriter.Boundary())
+	_, fileHeader, err := req.FormFile("file")
+	if err != nil {
+		return nil, err
+	}
+	return fileHeader, nil
+}
@@ -0,0 +1,298 @@
+// Copyright 2015 Alex Browne.  All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package forms
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Storage is a place where uploaded files can be saved. Implementations
+// are responsible for choosing the name under which a file is stored;
+// the path returned by Save can later be passed to Open or Remove.
+type Storage interface {
+	// Save reads all of r and stores it. filename is the name of the
+	// file as given by the client and should only be used as a hint
+	// (e.g. for the file extension). Save returns the path at which
+	// the file was stored.
+	Save(filename string, r io.Reader) (string, error)
+	// Open opens the file stored at path for reading.
+	Open(path string) (io.ReadCloser, error)
+	// Remove deletes the file stored at path.
+	Remove(path string) error
+}
+
+// StoredFile holds information about a file which was saved to a
+// Storage by SaveFile.
+type StoredFile struct {
+	// Path is the path returned by Storage.Save.
+	Path string
+	// Filename is the original name of the file as given by the client.
+	Filename string
+	// Size is the number of bytes written.
+	Size int64
+	// Checksum is the hex-encoded SHA-256 checksum of the file contents.
+	Checksum string
+}
+
+// SaveFile saves the file associated with key to storage and returns
+// the stored path, size, and checksum. If there is no file associated
+// with key, it returns nil (not an error).
+func (d Data) SaveFile(key string, storage Storage) (*StoredFile, error) {
+	header, found := d.Files[key]
+	if !found {
+		return nil, nil
+	}
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	counter := &countingWriter{}
+	r := io.TeeReader(file, io.MultiWriter(hash, counter))
+	storedPath, err := storage.Save(header.Filename, r)
+	if err != nil {
+		return nil, err
+	}
+	return &StoredFile{
+		Path:     storedPath,
+		Filename: header.Filename,
+		Size:     counter.n,
+		Checksum: hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
+
+// countingWriter counts the number of bytes written to it.
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// LocalStorage is a Storage which saves files to a directory on the local
+// filesystem. Files are given randomly generated names (keeping only a
+// sanitized version of the original extension) and are spread across
+// subdirectories so that no single directory grows too large. Files are
+// first written to a temporary file and then renamed into place, so a
+// partially written file is never visible at its final path.
+type LocalStorage struct {
+	// Dir is the root directory under which files are stored.
+	Dir string
+	// ShardDepth is the number of levels of subdirectories to use.
+	// Each level is named after the next two characters of the
+	// generated file name, e.g. "3f/a2/3fa2...". A ShardDepth of
+	// 0 stores all files directly in Dir.
+	ShardDepth int
+	// FilePerm is the permission used for stored files. A FilePerm of 0
+	// means 0644.
+	FilePerm os.FileMode
+	// DirPerm is the permission used for any created directories. A
+	// DirPerm of 0 means 0755.
+	DirPerm os.FileMode
+}
+
+// NewLocalStorage returns a LocalStorage which stores files under dir
+// using two levels of sharding, 0644 file permissions, and 0755
+// directory permissions.
+func NewLocalStorage(dir string) *LocalStorage {
+	return &LocalStorage{
+		Dir:        dir,
+		ShardDepth: 2,
+		FilePerm:   0644,
+		DirPerm:    0755,
+	}
+}
+
+// Save writes the contents of r to a new file under s.Dir and returns
+// its path relative to s.Dir, using forward slashes as separators.
+func (s *LocalStorage) Save(filename string, r io.Reader) (string, error) {
+	name, err := generateStorageName(filename)
+	if err != nil {
+		return "", err
+	}
+	storedPath := shardPath(name, s.ShardDepth)
+	fullPath := filepath.Join(s.Dir, filepath.FromSlash(storedPath))
+	dir := filepath.Dir(fullPath)
+	dirPerm, filePerm := s.DirPerm, s.FilePerm
+	if dirPerm == 0 {
+		dirPerm = 0755
+	}
+	if filePerm == 0 {
+		filePerm = 0644
+	}
+	if err := os.MkdirAll(dir, dirPerm); err != nil {
+		return "", err
+	}
+	tmp, err := ioutil.TempFile(dir, ".upload-")
+	if err != nil {
+		return "", err
+	}
+	tmpName := tmp.Name()
+	if err := writeAndClose(tmp, r); err != nil {
+		os.Remove(tmpName)
+		return "", err
+	}
+	if err := os.Chmod(tmpName, filePerm); err != nil {
+		os.Remove(tmpName)
+		return "", err
+	}
+	if err := os.Rename(tmpName, fullPath); err != nil {
+		os.Remove(tmpName)
+		return "", err
+	}
+	return storedPath, nil
+}
+
+func writeAndClose(f *os.File, r io.Reader) error {
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Open opens the file stored at path (as returned by Save).
+func (s *LocalStorage) Open(path string) (io.ReadCloser, error) {
+	fullPath, err := s.fullPath(path)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(fullPath)
+}
+
+// Remove deletes the file stored at path (as returned by Save).
+func (s *LocalStorage) Remove(path string) error {
+	fullPath, err := s.fullPath(path)
+	if err != nil {
+		return err
+	}
+	return os.Remove(fullPath)
+}
+
+// fullPath converts a stored path to a path on the filesystem, refusing
+// any path which would point outside of s.Dir.
+func (s *LocalStorage) fullPath(storedPath string) (string, error) {
+	localPath := filepath.FromSlash(storedPath)
+	if !filepath.IsLocal(localPath) {
+		return "", fmt.Errorf("forms: invalid storage path %q", storedPath)
+	}
+	return filepath.Join(s.Dir, localPath), nil
+}
+
+// MemoryStorage is a Storage which keeps files in memory. It is safe for
+// concurrent use and is primarily intended for tests.
+type MemoryStorage struct {
+	mut   sync.Mutex
+	files map[string][]byte
+}
+
+// NewMemoryStorage returns an empty MemoryStorage.
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		files: map[string][]byte{},
+	}
+}
+
+// Save reads all of r into memory and returns a generated path for it.
+func (s *MemoryStorage) Save(filename string, r io.Reader) (string, error) {
+	name, err := generateStorageName(filename)
+	if err != nil {
+		return "", err
+	}
+	contents, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.files[name] = contents
+	return name, nil
+}
+
+// Open returns a reader for the file stored at path.
+func (s *MemoryStorage) Open(path string) (io.ReadCloser, error) {
+	contents, found := s.Get(path)
+	if !found {
+		return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
+	}
+	return ioutil.NopCloser(bytes.NewReader(contents)), nil
+}
+
+// Remove deletes the file stored at path.
+func (s *MemoryStorage) Remove(path string) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if _, found := s.files[path]; !found {
+		return &os.PathError{Op: "remove", Path: path, Err: os.ErrNotExist}
+	}
+	delete(s.files, path)
+	return nil
+}
+
+// Get returns the contents of the file stored at path and whether or
+// not it exists.
+func (s *MemoryStorage) Get(path string) ([]byte, bool) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	contents, found := s.files[path]
+	return contents, found
+}
+
+// generateStorageName returns a random file name which keeps the
+// extension of filename if it is safe to do so.
+func generateStorageName(filename string) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf) + sanitizeExt(filename), nil
+}
+
+// sanitizeExt returns the lowercased extension of filename (including the
+// preceding "."), or the empty string if the extension is missing, too long,
+// or contains anything other than ASCII letters and digits.
+func sanitizeExt(filename string) string {
+	// Clients may send windows-style paths, so treat backslashes
+	// as separators too.
+	base := path.Base(strings.Replace(filename, "\\", "/", -1))
+	ext := strings.ToLower(path.Ext(base))
+	if len(ext) < 2 || len(ext) > 11 {
+		return ""
+	}
+	for _, c := range ext[1:] {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9') {
+			return ""
+		}
+	}
+	return ext
+}
+
+// shardPath prefixes name with depth levels of directories, each named
+// after the next two characters of name.
+func shardPath(name string, depth int) string {
+	parts := []string{}
+	for i := 0; i < depth && len(name) >= (i+1)*2; i++ {
+		parts = append(parts, name[i*2:(i+1)*2])
+	}
+	return path.Join(append(parts, name)...)
+}
@@ -0,0 +1,157 @@
+// Copyright 2015 Alex Browne.  All rights reserved.
+// Use of this source code is governed by the MIT
+// license, which can be found in the LICENSE file.
+
+package forms
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSaveFileLocalStorage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "forms-storage-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	data := newData()
+	content := []byte("Hello!\n")
+	fileHeader, err := createTestFileHeader("../../evil.PNG", content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data.AddFile("file", fileHeader)
+
+	storage := NewLocalStorage(dir)
+	storage.FilePerm = 0600
+	stored, err := data.SaveFile("file", storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Size != int64(len(content)) {
+		t.Errorf("Expected Size to be %d but got %d.", len(content), stored.Size)
+	}
+	sum := sha256.Sum256(content)
+	if expected := hex.EncodeToString(sum[:]); stored.Checksum != expected {
+		t.Errorf("Expected Checksum to be %s but got %s.", expected, stored.Checksum)
+	}
+	if !strings.HasSuffix(stored.Path, ".png") {
+		t.Errorf("Expected stored path to keep the .png extension but got %s.", stored.Path)
+	}
+	if parts := strings.Split(stored.Path, "/"); len(parts) != 3 {
+		t.Errorf("Expected stored path to have 2 levels of sharding but got %s.", stored.Path)
+	}
+	info, err := os.Stat(filepath.Join(dir, filepath.FromSlash(stored.Path)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected file permissions to be 0600 but got %o.", info.Mode().Perm())
+	}
+
+	file, err := storage.Open(stored.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotBytes, err := ioutil.ReadAll(file)
+	file.Close()
+	if err != nil {
+		t.Error(err)
+	}
+	if string(gotBytes) != string(content) {
+		t.Errorf("Expected stored contents to be %q but got %q.", content, gotBytes)
+	}
+
+	if _, err := storage.Open("../" + stored.Path); err == nil {
+		t.Error("Expected an error when opening a path outside of Dir but got none.")
+	}
+	if err := storage.Remove(stored.Path); err != nil {
+		t.Error(err)
+	}
+	if _, err := storage.Open(stored.Path); !os.IsNotExist(err) {
+		t.Errorf("Expected a not exist error after Remove but got %v.", err)
+	}
+}
+
+func TestLocalStorageDefaultPerms(t *testing.T) {
+	dir, err := ioutil.TempDir("", "forms-storage-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	storage := &LocalStorage{Dir: dir, ShardDepth: 1}
+	storedPath, err := storage.Save("test_file.txt", strings.NewReader("Hello!"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fullPath := filepath.Join(dir, filepath.FromSlash(storedPath))
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("Expected file permissions to be 0644 but got %o.", info.Mode().Perm())
+	}
+	dirInfo, err := os.Stat(filepath.Dir(fullPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dirInfo.Mode().Perm()&0700 != 0700 {
+		t.Errorf("Expected directory to be accessible by its owner but got permissions %o.", dirInfo.Mode().Perm())
+	}
+}
+
+func TestSaveFileMemoryStorage(t *testing.T) {
+	data := newData()
+	fileHeader, err := createTestFileHeader("test_file.txt", []byte("Hello!"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data.AddFile("file", fileHeader)
+
+	storage := NewMemoryStorage()
+	stored, err := data.SaveFile("file", storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, found := storage.Get(stored.Path); !found {
+		t.Errorf("Expected file to be stored at %s but it was not.", stored.Path)
+	} else if string(got) != "Hello!" {
+		t.Errorf(`Expected stored contents to be "Hello!" but got %q.`, got)
+	}
+	if stored.Filename != "test_file.txt" {
+		t.Errorf(`Expected Filename to be "test_file.txt" but got %s.`, stored.Filename)
+	}
+
+	if stored, err := data.SaveFile("missing", storage); err != nil || stored != nil {
+		t.Errorf("Expected nil, nil for a missing file but got %v, %v.", stored, err)
+	}
+}
+
+func TestSanitizeExt(t *testing.T) {
+	table := []struct {
+		filename string
+		expected string
+	}{
+		{"photo.JPG", ".jpg"},
+		{"archive.tar.gz", ".gz"},
+		{"noext", ""},
+		{"C:\\Users\\bob\\file.txt", ".txt"},
+		{"bad.ex e", ""},
+		{"dots.", ""},
+		{"long.abcdefghijkl", ""},
+	}
+	for _, test := range table {
+		if got := sanitizeExt(test.filename); got != test.expected {
+			t.Errorf("sanitizeExt(%q) was incorrect. Expected %q, but got %q.", test.filename, test.expected, got)
+		}
+	}
+}
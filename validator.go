@@ -6,10 +6,12 @@ package forms
 
 import (
 	"fmt"
+	"net/url"
 	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Validator has methods for validating its underlying Data.
@@ -31,6 +33,8 @@ type ValidationResult struct {
 	Ok      bool
 	field   string
 	message string
+	code    string
+	params  map[string]string
 }
 
 var validationOk = &ValidationResult{Ok: true}
@@ -49,6 +53,24 @@ func (vr *ValidationResult) Message(msg string) *ValidationResult {
 	return vr
 }
 
+// Code changes the machine-readable code associated with the validation
+// result, e.g. "url". Codes are intended for clients which need to handle
+// specific errors, such as translating their messages. Only some validation
+// methods set a code by default.
+func (vr *ValidationResult) Code(code string) *ValidationResult {
+	vr.code = code
+	return vr
+}
+
+// Params changes the parameters associated with the validation result,
+// e.g. {"min": "3"}. Like codes, params are intended for clients which
+// build their own messages. They are set by rules which have parameters
+// when the rule is applied with Apply or Check.
+func (vr *ValidationResult) Params(params map[string]string) *ValidationResult {
+	vr.params = params
+	return vr
+}
+
 // AddError adds an error associated with field to the validator. msg
 // should typically be a user-readable sentence, such as "username
 // is required."
@@ -87,6 +109,27 @@ func (v *Validator) Fields() []string {
 	return fields
 }
 
+// Codes returns the codes for all validation results for the
+// Validator, in order. The code for a result without one is an
+// empty string.
+func (v *Validator) Codes() []string {
+	codes := []string{}
+	for _, vr := range v.results {
+		codes = append(codes, vr.code)
+	}
+	return codes
+}
+
+// ParamsList returns the params for all validation results for the
+// Validator, in order. The params for a result without any are nil.
+func (v *Validator) ParamsList() []map[string]string {
+	paramsList := []map[string]string{}
+	for _, vr := range v.results {
+		paramsList = append(paramsList, vr.params)
+	}
+	return paramsList
+}
+
 // ErrorMap reutrns all the fields and error messages for
 // the validator in the form of a map. The keys of the map
 // are field names, and the values are any error messages
@@ -114,17 +157,104 @@ func (v *Validator) Require(field string) *ValidationResult {
 	}
 }
 
+// RequireIf will add an error to the Validator if data.Values[field]
+// does not exist, is an empty string, or consists of only whitespace,
+// but only if the first value of data.Values[otherField] is one of
+// values. For example, RequireIf("company_name", "account_type",
+// "business") requires company_name for business accounts.
+func (v *Validator) RequireIf(field string, otherField string, values ...string) *ValidationResult {
+	if !v.isOneOf(otherField, values) || !v.isBlank(field) {
+		return validationOk
+	}
+	msg := fmt.Sprintf("%s is required when %s is %s.", field, otherField, humanList(values, "or"))
+	return v.AddError(field, msg)
+}
+
+// RequireUnless will add an error to the Validator if data.Values[field]
+// does not exist, is an empty string, or consists of only whitespace,
+// unless the first value of data.Values[otherField] is one of values.
+func (v *Validator) RequireUnless(field string, otherField string, values ...string) *ValidationResult {
+	if v.isOneOf(otherField, values) || !v.isBlank(field) {
+		return validationOk
+	}
+	msg := fmt.Sprintf("%s is required unless %s is %s.", field, otherField, humanList(values, "or"))
+	return v.AddError(field, msg)
+}
+
+// RequireWith will add an error to the Validator if data.Values[field]
+// does not exist, is an empty string, or consists of only whitespace,
+// but only if any of otherFields are present (i.e. not blank).
+func (v *Validator) RequireWith(field string, otherFields ...string) *ValidationResult {
+	if !v.isBlank(field) {
+		return validationOk
+	}
+	for _, otherField := range otherFields {
+		if !v.isBlank(otherField) {
+			msg := fmt.Sprintf("%s is required when %s is present.", field, humanList(otherFields, "or"))
+			return v.AddError(field, msg)
+		}
+	}
+	return validationOk
+}
+
+// RequireWithout will add an error to the Validator if data.Values[field]
+// does not exist, is an empty string, or consists of only whitespace,
+// but only if any of otherFields are not present (i.e. blank). For
+// example, RequireWithout("phone", "email") means that either phone or
+// email is required.
+func (v *Validator) RequireWithout(field string, otherFields ...string) *ValidationResult {
+	if !v.isBlank(field) {
+		return validationOk
+	}
+	for _, otherField := range otherFields {
+		if v.isBlank(otherField) {
+			msg := fmt.Sprintf("%s is required when %s is not present.", field, humanList(otherFields, "or"))
+			return v.AddError(field, msg)
+		}
+	}
+	return validationOk
+}
+
+// When calls fn with the Validator iff predicate returns true for the
+// underlying data. It can be used to group any number of rules which only
+// apply in certain cases, e.g.:
+//
+//	val.When(func(d *Data) bool { return d.GetBool("subscribe") }, func(val *Validator) {
+//		val.Require("email")
+//		val.MatchEmail("email")
+//	})
+func (v *Validator) When(predicate func(d *Data) bool, fn func(v *Validator)) {
+	if predicate(v.data) {
+		fn(v)
+	}
+}
+
+// isBlank returns true iff data.Values[field] does not exist, is an
+// empty string, or consists of only whitespace.
+func (v *Validator) isBlank(field string) bool {
+	return strings.TrimSpace(v.data.Get(field)) == ""
+}
+
+// isOneOf returns true iff the first value of data.Values[field]
+// is in values.
+func (v *Validator) isOneOf(field string, values []string) bool {
+	got := v.data.Get(field)
+	for _, value := range values {
+		if got == value {
+			return true
+		}
+	}
+	return false
+}
+
 // RequireFile will add an error to the Validator if data.Files[field]
-// does not exist or is an empty file
+// does not exist or is an empty file. It uses the size recorded in the
+// file header and never reads the contents of the file.
 func (v *Validator) RequireFile(field string) *ValidationResult {
 	if !v.data.FileExists(field) {
 		return v.addRequiredError(field)
 	}
-	bytes, err := v.data.GetFileBytes(field)
-	if err != nil {
-		return v.AddError(field, "Could not read file.")
-	}
-	if len(bytes) == 0 {
+	if v.data.GetFile(field).Size == 0 {
 		return v.addFileEmptyError(field)
 	}
 	return validationOk
@@ -225,11 +355,13 @@ func (v *Validator) Match(field string, regex *regexp.Regexp) *ValidationResult
 	}
 }
 
+var emailRegex = regexp.MustCompile("^[\\w!#$%&'*+/=?^_`{|}~-]+(?:\\.[\\w!#$%&'*+/=?^_`{|}~-]+)*@(?:[\\w](?:[\\w-]*[\\w])?\\.)+[a-zA-Z0-9](?:[\\w-]*[\\w])?$")
+
 // MatchEmail will add an error to the Validator if data.Values[field]
-// does not match the formatting expected of an email.
+// does not match the formatting expected of an email. See Email for a
+// stricter alternative which supports internationalized addresses.
 func (v *Validator) MatchEmail(field string) *ValidationResult {
-	regex := regexp.MustCompile("^[\\w!#$%&'*+/=?^_`{|}~-]+(?:\\.[\\w!#$%&'*+/=?^_`{|}~-]+)*@(?:[\\w](?:[\\w-]*[\\w])?\\.)+[a-zA-Z0-9](?:[\\w-]*[\\w])?$")
-	return v.Match(field, regex)
+	return v.Match(field, emailRegex)
 }
 
 func (v *Validator) addMatchError(field string) *ValidationResult {
@@ -237,6 +369,135 @@ func (v *Validator) addMatchError(field string) *ValidationResult {
 	return v.AddError(field, msg)
 }
 
+// In will add an error to the Validator if any value of
+// data.Values[field] is not one of values. Unlike most validation
+// methods, every value for field is checked, not just the first.
+// If field does not exist, it does not add an error to the Validator.
+func (v *Validator) In(field string, values ...string) *ValidationResult {
+	return v.in(field, values, false, true)
+}
+
+// InFold is like In but compares values without regard to case.
+func (v *Validator) InFold(field string, values ...string) *ValidationResult {
+	return v.in(field, values, true, true)
+}
+
+// NotIn will add an error to the Validator if any value of
+// data.Values[field] is one of values. Unlike most validation methods,
+// every value for field is checked, not just the first.
+func (v *Validator) NotIn(field string, values ...string) *ValidationResult {
+	return v.in(field, values, false, false)
+}
+
+// NotInFold is like NotIn but compares values without regard to case.
+func (v *Validator) NotInFold(field string, values ...string) *ValidationResult {
+	return v.in(field, values, true, false)
+}
+
+// in adds an error to the Validator unless each value of data.Values[field]
+// is (if want is true) or is not (if want is false) one of values.
+func (v *Validator) in(field string, values []string, fold bool, want bool) *ValidationResult {
+	for _, got := range v.data.Values[field] {
+		found := false
+		for _, value := range values {
+			if got == value || fold && strings.EqualFold(got, value) {
+				found = true
+				break
+			}
+		}
+		if found != want {
+			if want {
+				msg := fmt.Sprintf("%s must be one of %s.", field, humanList(values, "or"))
+				return v.AddError(field, msg)
+			}
+			msg := fmt.Sprintf("%s cannot be %s.", field, humanList(values, "or"))
+			return v.AddError(field, msg)
+		}
+	}
+	return validationOk
+}
+
+// Each calls fn once for every value of data.Values[field] (or for every
+// element if the value is a json array), so that any validation method can
+// be applied to all of them instead of only the first. Inside fn, field
+// refers to the current value, and all other fields are unchanged. Errors
+// for field are added to v with the field name changed to include the index
+// of the value, e.g. "tags[2]". fn is meant to validate field, but if it
+// adds errors for other fields, each distinct error is only added once
+// rather than once for every value.
+func (v *Validator) Each(field string, fn func(ev *Validator)) {
+	type fieldMessage struct{ field, message string }
+	seen := map[fieldMessage]bool{}
+	for i, elem := range v.data.elements(field) {
+		elemData := &Data{
+			Values: url.Values{},
+			Files:  v.data.Files,
+		}
+		for key, vals := range v.data.Values {
+			elemData.Values[key] = vals
+		}
+		elemData.Values[field] = []string{elem}
+		ev := elemData.Validator()
+		fn(ev)
+		for _, result := range ev.results {
+			if result.field == field {
+				result.field = fmt.Sprintf("%s[%d]", field, i)
+			} else {
+				key := fieldMessage{result.field, result.message}
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+			}
+			v.results = append(v.results, result)
+		}
+	}
+}
+
+// MinCount will add an error to the Validator if data.Values[field] has
+// fewer than count values (or elements if the value is a json array).
+func (v *Validator) MinCount(field string, count int) *ValidationResult {
+	if len(v.data.elements(field)) < count {
+		msg := fmt.Sprintf("%s must have at least %s.", field, countNoun(count, "value"))
+		return v.AddError(field, msg)
+	}
+	return validationOk
+}
+
+// MaxCount will add an error to the Validator if data.Values[field] has
+// more than count values (or elements if the value is a json array).
+func (v *Validator) MaxCount(field string, count int) *ValidationResult {
+	if len(v.data.elements(field)) > count {
+		msg := fmt.Sprintf("%s cannot have more than %s.", field, countNoun(count, "value"))
+		return v.AddError(field, msg)
+	}
+	return validationOk
+}
+
+// countNoun returns count followed by noun, which is made plural
+// if count is not 1, e.g. "1 value" or "3 values".
+func countNoun(count int, noun string) string {
+	if count == 1 {
+		return fmt.Sprintf("%d %s", count, noun)
+	}
+	return fmt.Sprintf("%d %ss", count, noun)
+}
+
+// Unique will add an error to the Validator if any two values of
+// data.Values[field] (or elements if the value is a json array)
+// are the same.
+func (v *Validator) Unique(field string) *ValidationResult {
+	seen := map[string]bool{}
+	for _, elem := range v.data.elements(field) {
+		if seen[elem] {
+			msg := fmt.Sprintf("%s cannot contain duplicate values.", field)
+			return v.AddError(field, msg)
+		}
+		seen[elem] = true
+	}
+	return validationOk
+}
+
 // TypeInt will add an error to the Validator if the first
 // element of data.Values[field] cannot be converted to an int.
 func (v *Validator) TypeInt(field string) *ValidationResult {
@@ -258,10 +519,11 @@ func (v *Validator) TypeFloat(field string) *ValidationResult {
 	}
 }
 
-// TypeBool will add an error to the Validator if the first
-// element of data.Values[field] cannot be converted to a bool.
+// TypeBool will add an error to the Validator if the values of
+// data.Values[field] cannot be converted to a bool using TrueValues and
+// FalseValues, as with GetBool.
 func (v *Validator) TypeBool(field string) *ValidationResult {
-	if _, err := strconv.ParseBool(v.data.Get(field)); err != nil {
+	if _, err := parseBoolValues(v.data.Values[field]); err != nil {
 		// note: "true or false" is a more natural colloquial term than "bool"
 		return v.addTypeError(field, "true or false")
 	} else {
@@ -269,6 +531,46 @@ func (v *Validator) TypeBool(field string) *ValidationResult {
 	}
 }
 
+// TypeInt64 will add an error to the Validator if the first
+// element of data.Values[field] cannot be converted to an int64.
+func (v *Validator) TypeInt64(field string) *ValidationResult {
+	if _, err := strconv.ParseInt(v.data.Get(field), 10, 64); err != nil {
+		return v.addTypeError(field, "integer")
+	} else {
+		return validationOk
+	}
+}
+
+// TypeUint64 will add an error to the Validator if the first
+// element of data.Values[field] cannot be converted to a uint64.
+func (v *Validator) TypeUint64(field string) *ValidationResult {
+	if _, err := strconv.ParseUint(v.data.Get(field), 10, 64); err != nil {
+		return v.addTypeError(field, "non-negative integer")
+	} else {
+		return validationOk
+	}
+}
+
+// TypeDuration will add an error to the Validator if the first
+// element of data.Values[field] cannot be converted to a time.Duration.
+func (v *Validator) TypeDuration(field string) *ValidationResult {
+	if _, err := time.ParseDuration(v.data.Get(field)); err != nil {
+		return v.addTypeError(field, "duration")
+	} else {
+		return validationOk
+	}
+}
+
+// TypeDecimal will add an error to the Validator if the first
+// element of data.Values[field] is not a decimal number.
+func (v *Validator) TypeDecimal(field string) *ValidationResult {
+	if _, err := parseDecimal(v.data.Get(field)); err != nil {
+		return v.addTypeError(field, "number")
+	} else {
+		return validationOk
+	}
+}
+
 func (v *Validator) addTypeError(field string, typ string) *ValidationResult {
 	article := "a"
 	if strings.Contains("aeiou", string(typ[0])) {
@@ -337,6 +639,78 @@ func (v *Validator) inequality(field string, value float64, condition conditiona
 	}
 }
 
+// Int64Range will add an error to the Validator if the first element of
+// data.Values[field] is less than min or greater than max, or if it cannot
+// be converted to an int64. Values are compared exactly, without being
+// converted to float64.
+func (v *Validator) Int64Range(field string, min int64, max int64) *ValidationResult {
+	got, err := strconv.ParseInt(v.data.Get(field), 10, 64)
+	if err != nil {
+		return v.addTypeError(field, "integer")
+	}
+	if got < min || got > max {
+		return v.addRangeError(field, strconv.FormatInt(min, 10), strconv.FormatInt(max, 10))
+	}
+	return validationOk
+}
+
+// Uint64Range will add an error to the Validator if the first element of
+// data.Values[field] is less than min or greater than max, or if it cannot
+// be converted to a uint64.
+func (v *Validator) Uint64Range(field string, min uint64, max uint64) *ValidationResult {
+	got, err := strconv.ParseUint(v.data.Get(field), 10, 64)
+	if err != nil {
+		return v.addTypeError(field, "non-negative integer")
+	}
+	if got < min || got > max {
+		return v.addRangeError(field, strconv.FormatUint(min, 10), strconv.FormatUint(max, 10))
+	}
+	return validationOk
+}
+
+// DurationRange will add an error to the Validator if the first element of
+// data.Values[field] is shorter than min or longer than max, or if it cannot
+// be converted to a time.Duration.
+func (v *Validator) DurationRange(field string, min time.Duration, max time.Duration) *ValidationResult {
+	got, err := time.ParseDuration(v.data.Get(field))
+	if err != nil {
+		return v.addTypeError(field, "duration")
+	}
+	if got < min || got > max {
+		return v.addRangeError(field, min.String(), max.String())
+	}
+	return validationOk
+}
+
+// DecimalRange will add an error to the Validator if the first element of
+// data.Values[field] is less than min or greater than max, or if it is not a
+// decimal number. min and max are decimal numbers such as "0.01", and the
+// comparison is exact. DecimalRange panics if min or max is not a valid
+// decimal number.
+func (v *Validator) DecimalRange(field string, min string, max string) *ValidationResult {
+	minRat, err := parseDecimal(min)
+	if err != nil {
+		panic(err)
+	}
+	maxRat, err := parseDecimal(max)
+	if err != nil {
+		panic(err)
+	}
+	got, err := parseDecimal(v.data.Get(field))
+	if err != nil {
+		return v.addTypeError(field, "number")
+	}
+	if got.Cmp(minRat) < 0 || got.Cmp(maxRat) > 0 {
+		return v.addRangeError(field, min, max)
+	}
+	return validationOk
+}
+
+func (v *Validator) addRangeError(field string, min string, max string) *ValidationResult {
+	msg := fmt.Sprintf("%s must be between %s and %s.", field, min, max)
+	return v.AddError(field, msg)
+}
+
 // AcceptFileExts will add an error to the Validator if the extension
 // of the file identified by field is not in exts. exts should be one ore more
 // allowed file extensions, not including the preceding ".". If the file does not
@@ -356,30 +730,101 @@ func (v *Validator) AcceptFileExts(field string, exts ...string) *ValidationResu
 }
 
 func (v *Validator) addFileExtError(field string, gotExt string, allowedExts ...string) *ValidationResult {
-	msg := fmt.Sprintf("The file extension %s is not allowed. Allowed extensions include: ", gotExt)
+	msg := fmt.Sprintf("The file extension %s is not allowed. Allowed extensions include: %s", gotExt, humanList(allowedExts, "and"))
+	return v.AddError(field, msg)
+}
 
-	// Append each allowed extension to the message, in a human-readable list
-	// e.g. "x, y, and z"
-	for i, ext := range allowedExts {
-		if i == len(allowedExts)-1 {
+// humanList joins items into a human-readable list using conjunction
+// before the last element, e.g. "x, y, and z" or "x or y".
+func humanList(items []string, conjunction string) string {
+	list := ""
+	for i, item := range items {
+		if i == len(items)-1 {
 			// special case for the last element
-			switch len(allowedExts) {
+			switch len(items) {
 			case 1:
-				msg += ext
+				list += item
 			default:
-				msg += fmt.Sprintf("and %s", ext)
+				list += fmt.Sprintf("%s %s", conjunction, item)
 			}
 		} else {
 			// default case for middle elements
 			// we only reach here if there is at least
 			// one element
-			switch len(allowedExts) {
+			switch len(items) {
 			case 2:
-				msg += fmt.Sprintf("%s ", ext)
+				list += fmt.Sprintf("%s ", item)
 			default:
-				msg += fmt.Sprintf("%s, ", ext)
+				list += fmt.Sprintf("%s, ", item)
 			}
 		}
 	}
-	return v.AddError(field, msg)
+	return list
+}
+
+// MinFileSize will add an error to the Validator if the file identified
+// by field is smaller than size bytes. It uses the size recorded in the
+// file header and never reads the contents of the file. If the file does
+// not exist, it does not add an error to the Validator.
+func (v *Validator) MinFileSize(field string, size int64) *ValidationResult {
+	if !v.data.FileExists(field) {
+		return validationOk
+	}
+	if v.data.GetFile(field).Size < size {
+		msg := fmt.Sprintf("%s must be at least %s.", field, formatFileSize(size))
+		return v.AddError(field, msg)
+	}
+	return validationOk
+}
+
+// MaxFileSize will add an error to the Validator if the file identified
+// by field is larger than size bytes. It uses the size recorded in the
+// file header and never reads the contents of the file. If the file does
+// not exist, it does not add an error to the Validator.
+func (v *Validator) MaxFileSize(field string, size int64) *ValidationResult {
+	if !v.data.FileExists(field) {
+		return validationOk
+	}
+	if v.data.GetFile(field).Size > size {
+		msg := fmt.Sprintf("%s cannot be larger than %s.", field, formatFileSize(size))
+		return v.AddError(field, msg)
+	}
+	return validationOk
+}
+
+// FileSizeRange will add an error to the Validator if the file identified
+// by field is smaller than min bytes or larger than max bytes. It uses the
+// size recorded in the file header and never reads the contents of the
+// file. If the file does not exist, it does not add an error to the
+// Validator.
+func (v *Validator) FileSizeRange(field string, min int64, max int64) *ValidationResult {
+	if !v.data.FileExists(field) {
+		return validationOk
+	}
+	if size := v.data.GetFile(field).Size; size < min || size > max {
+		msg := fmt.Sprintf("%s must be between %s and %s.", field, formatFileSize(min), formatFileSize(max))
+		return v.AddError(field, msg)
+	}
+	return validationOk
+}
+
+// formatFileSize converts size to a human-readable string, e.g.
+// "512 bytes" or "1.5 MB".
+func formatFileSize(size int64) string {
+	if size == 1 {
+		return "1 byte"
+	}
+	if size < 1024 {
+		return fmt.Sprintf("%d bytes", size)
+	}
+	value := float64(size)
+	units := []string{"KB", "MB", "GB", "TB"}
+	unit := ""
+	for _, unit = range units {
+		value /= 1024
+		if value < 1024 {
+			break
+		}
+	}
+	return strings.TrimSuffix(fmt.Sprintf("%.1f", value), ".0") + " " + unit
 }
@@ -7,12 +7,15 @@ package forms
 import (
 	"bytes"
 	"fmt"
+	"math"
 	"mime/multipart"
 	"net/http"
 	"net/url"
+	"reflect"
 	"regexp"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestCustomMessage(t *testing.T) {
@@ -61,6 +64,78 @@ func TestRequire(t *testing.T) {
 	}
 }
 
+func TestRequireIf(t *testing.T) {
+	data := newData()
+	data.Add("account_type", "business")
+	data.Add("company_name", "Acme")
+
+	val := data.Validator()
+	val.RequireIf("company_name", "account_type", "business")
+	val.RequireIf("tax_id", "account_type", "personal")
+	val.RequireUnless("tax_id", "account_type", "business")
+	if val.HasErrors() {
+		t.Errorf("Expected no errors but got errors: %v", val.Messages())
+	}
+
+	val.RequireIf("tax_id", "account_type", "business", "nonprofit")
+	val.RequireUnless("birthday", "account_type", "personal")
+	expected := []string{
+		"tax_id is required when account_type is business or nonprofit.",
+		"birthday is required unless account_type is personal.",
+	}
+	if !reflect.DeepEqual(val.Messages(), expected) {
+		t.Errorf("Expected messages %v but got %v.", expected, val.Messages())
+	}
+}
+
+func TestRequireWith(t *testing.T) {
+	data := newData()
+	data.Add("email", "bob@example.com")
+	data.Add("street", "123 Main St")
+	data.Add("city", " ")
+
+	val := data.Validator()
+	val.RequireWith("phone", "fax")
+	val.RequireWithout("phone", "email")
+	val.RequireWith("street", "city")
+	if val.HasErrors() {
+		t.Errorf("Expected no errors but got errors: %v", val.Messages())
+	}
+
+	val.RequireWith("city", "street", "zip")
+	val.RequireWithout("email", "phone")
+	val.RequireWithout("fax", "email", "phone")
+	expected := []string{
+		"city is required when street or zip is present.",
+		"fax is required when email or phone is not present.",
+	}
+	if !reflect.DeepEqual(val.Messages(), expected) {
+		t.Errorf("Expected messages %v but got %v.", expected, val.Messages())
+	}
+}
+
+func TestWhen(t *testing.T) {
+	data := newData()
+	data.Add("subscribe", "true")
+	subscribed := func(d *Data) bool { return d.GetBool("subscribe") }
+	notSubscribed := func(d *Data) bool { return !d.GetBool("subscribe") }
+
+	val := data.Validator()
+	val.When(notSubscribed, func(val *Validator) {
+		val.Require("reason")
+	})
+	if val.HasErrors() {
+		t.Errorf("Expected no errors but got errors: %v", val.Messages())
+	}
+	val.When(subscribed, func(val *Validator) {
+		val.Require("email")
+		val.MatchEmail("email")
+	})
+	if len(val.Messages()) != 2 {
+		t.Errorf("Expected 2 validation errors but got %d.", len(val.Messages()))
+	}
+}
+
 func TestRequireFile(t *testing.T) {
 	data := newData()
 	val := data.Validator()
@@ -134,7 +209,7 @@ func TestMinLength(t *testing.T) {
 	val.MinLength("one", 1)
 	val.MinLength("three", 3)
 	if val.HasErrors() {
-		t.Error("Expected no errors but got errors: %v", val.Messages())
+		t.Errorf("Expected no errors but got errors: %v", val.Messages())
 	}
 
 	val.MinLength("five", 5)
@@ -240,6 +315,171 @@ func TestMatchEmail(t *testing.T) {
 	}
 }
 
+func TestIn(t *testing.T) {
+	data := newData()
+	data.Add("status", "draft")
+	data.Add("color", "Red")
+	data.Add("tags", "news")
+	data.Add("tags", "sports")
+	data.Add("tags", "gossip")
+
+	val := data.Validator()
+	val.In("status", "draft", "published", "archived")
+	val.InFold("color", "red", "green")
+	val.In("missing", "a")
+	val.NotIn("status", "deleted")
+	val.NotInFold("color", "blue")
+	if val.HasErrors() {
+		t.Errorf("Expected no errors but got errors: %v", val.Messages())
+	}
+
+	val.In("color", "red", "green")
+	val.In("tags", "news", "sports")
+	val.NotIn("status", "draft", "deleted")
+	val.NotInFold("color", "RED")
+	expected := []string{
+		"color must be one of red or green.",
+		"tags must be one of news or sports.",
+		"status cannot be draft or deleted.",
+		"color cannot be RED.",
+	}
+	if !reflect.DeepEqual(val.Messages(), expected) {
+		t.Errorf("Expected messages %v but got %v.", expected, val.Messages())
+	}
+
+	val = data.Validator()
+	val.In("status", "draft", "published", "archived", "deleted")
+	val.In("tags", "news", "sports", "gossip")
+	if val.HasErrors() {
+		t.Errorf("Expected no errors but got errors: %v", val.Messages())
+	}
+}
+
+func TestEach(t *testing.T) {
+	data := newData()
+	data.Add("tags", "go")
+	data.Add("tags", "")
+	data.Add("tags", strings.Repeat("z", 20))
+	data.Add("minLength", "2")
+
+	val := data.Validator()
+	val.Each("tags", func(ev *Validator) {
+		ev.Require("tags")
+		ev.MaxLength("tags", 10)
+		ev.Require("minLength")
+	})
+	expectedFields := []string{"tags[1]", "tags[2]"}
+	if !reflect.DeepEqual(val.Fields(), expectedFields) {
+		t.Errorf("Expected fields %v but got %v.", expectedFields, val.Fields())
+	}
+
+	// errors for other fields are only added once
+	val = data.Validator()
+	val.Each("tags", func(ev *Validator) {
+		ev.Require("title")
+	})
+	expected := map[string][]string{"title": {"title is required."}}
+	if !reflect.DeepEqual(val.ErrorMap(), expected) {
+		t.Errorf("Expected errors %v but got %v.", expected, val.ErrorMap())
+	}
+
+	val = data.Validator()
+	val.Each("missing", func(ev *Validator) {
+		ev.Require("missing")
+	})
+	if val.HasErrors() {
+		t.Errorf("Expected no errors but got errors: %v", val.Messages())
+	}
+}
+
+func TestEachJSON(t *testing.T) {
+	req, err := http.NewRequest("POST", "/", strings.NewReader(`{"ids": [1, "two", 3, 3]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	data, err := Parse(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	val := data.Validator()
+	val.Each("ids", func(ev *Validator) {
+		ev.TypeInt("ids")
+	})
+	val.MinCount("ids", 5)
+	val.MaxCount("ids", 4)
+	val.Unique("ids")
+	expected := map[string][]string{
+		"ids[1]": {"ids must be an integer"},
+		"ids": {
+			"ids must have at least 5 values.",
+			"ids cannot contain duplicate values.",
+		},
+	}
+	if !reflect.DeepEqual(val.ErrorMap(), expected) {
+		t.Errorf("Expected errors %v but got %v.", expected, val.ErrorMap())
+	}
+}
+
+func TestEachJSONString(t *testing.T) {
+	req, err := http.NewRequest("POST", "/", strings.NewReader(`{"title": "[1,2]", "ids": [1, 2]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	data, err := Parse(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a json string which looks like an array is a single value
+	if got := data.elements("title"); !reflect.DeepEqual(got, []string{"[1,2]"}) {
+		t.Errorf("Expected elements of title to be [[1,2]] but got %v.", got)
+	}
+	val := data.Validator()
+	val.MaxCount("title", 1)
+	val.MinCount("ids", 2)
+	if val.HasErrors() {
+		t.Errorf("Expected no errors but got errors: %v", val.Messages())
+	}
+	// an array which has been replaced is not expanded either
+	data.Set("ids", "[1,2]")
+	if got := data.elements("ids"); !reflect.DeepEqual(got, []string{"[1,2]"}) {
+		t.Errorf("Expected elements of ids to be [[1,2]] after Set but got %v.", got)
+	}
+}
+
+func TestCount(t *testing.T) {
+	data := newData()
+	data.Add("tags", "a")
+	data.Add("tags", "b")
+	data.Add("tags", "c")
+	data.Add("json", "[1, 2]")
+
+	val := data.Validator()
+	val.MinCount("tags", 3)
+	val.MaxCount("tags", 3)
+	val.Unique("tags")
+	// not parsed from a json body, so it is a single value
+	val.MaxCount("json", 1)
+	val.MaxCount("missing", 0)
+	if val.HasErrors() {
+		t.Errorf("Expected no errors but got errors: %v", val.Messages())
+	}
+
+	data.Add("tags", "a")
+	val.MinCount("missing", 1)
+	val.MaxCount("tags", 3)
+	val.Unique("tags")
+	expected := []string{
+		"missing must have at least 1 value.",
+		"tags cannot have more than 3 values.",
+		"tags cannot contain duplicate values.",
+	}
+	if !reflect.DeepEqual(val.Messages(), expected) {
+		t.Errorf("Expected messages %v but got %v.", expected, val.Messages())
+	}
+}
+
 func TestTypeInt(t *testing.T) {
 	data := newData()
 	data.Add("age", "23")
@@ -292,6 +532,74 @@ func TestTypeBool(t *testing.T) {
 	}
 }
 
+func TestTypeNumeric(t *testing.T) {
+	data := newData()
+	data.Add("id", "9223372036854775807")
+	data.Add("quantity", "3")
+	data.Add("timeout", "1m30s")
+	data.Add("price", "19.99")
+	data.Add("negative", "-1")
+	data.Add("word", "ten")
+	data.Add("hex", "0x10")
+	val := data.Validator()
+	val.TypeInt64("id")
+	val.TypeUint64("quantity")
+	val.TypeDuration("timeout")
+	val.TypeDecimal("price")
+	if val.HasErrors() {
+		t.Errorf("Expected no errors but got errors: %v", val.Messages())
+	}
+
+	val.TypeInt64("price")
+	val.TypeUint64("negative")
+	val.TypeDuration("quantity")
+	val.TypeDecimal("word")
+	val.TypeDecimal("hex")
+	expected := []string{
+		"price must be an integer",
+		"negative must be a non-negative integer",
+		"quantity must be a duration",
+		"word must be a number",
+		"hex must be a number",
+	}
+	if !reflect.DeepEqual(val.Messages(), expected) {
+		t.Errorf("Expected messages %v but got %v.", expected, val.Messages())
+	}
+}
+
+func TestNumericRanges(t *testing.T) {
+	data := newData()
+	// 2^53 + 1 cannot be represented exactly as a float64
+	data.Add("id", "9007199254740993")
+	data.Add("quantity", "18446744073709551615")
+	data.Add("timeout", "90s")
+	data.Add("price", "0.30")
+	val := data.Validator()
+	val.Int64Range("id", 9007199254740993, 9007199254740995)
+	val.Uint64Range("quantity", 1, math.MaxUint64)
+	val.DurationRange("timeout", time.Minute, 2*time.Minute)
+	val.DecimalRange("price", "0.3", "0.30")
+	if val.HasErrors() {
+		t.Errorf("Expected no errors but got errors: %v", val.Messages())
+	}
+
+	val.Int64Range("id", 9007199254740994, 9007199254740995)
+	val.Uint64Range("quantity", 0, math.MaxUint64-1)
+	val.DurationRange("timeout", 0, time.Minute)
+	val.DecimalRange("price", "0.01", "0.29")
+	val.DecimalRange("missing", "0", "1")
+	expected := []string{
+		"id must be between 9007199254740994 and 9007199254740995.",
+		"quantity must be between 0 and 18446744073709551614.",
+		"timeout must be between 0s and 1m0s.",
+		"price must be between 0.01 and 0.29.",
+		"missing must be a number",
+	}
+	if !reflect.DeepEqual(val.Messages(), expected) {
+		t.Errorf("Expected messages %v but got %v.", expected, val.Messages())
+	}
+}
+
 func TestGreater(t *testing.T) {
 	data := newData()
 	data.Add("one", "1")
@@ -430,6 +738,55 @@ func TestAcceptFileExts(t *testing.T) {
 	}
 }
 
+func TestFileSize(t *testing.T) {
+	data := newData()
+	fileHeader, err := createTestFileHeader("test_file.txt", []byte("Hello!"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data.AddFile("file", fileHeader)
+
+	val := data.Validator()
+	val.MinFileSize("file", 6)
+	val.MaxFileSize("file", 6)
+	val.FileSizeRange("file", 1, 1024)
+	val.MinFileSize("missing", 1024)
+	if val.HasErrors() {
+		t.Errorf("Expected no errors but got errors: %v", val.Messages())
+	}
+
+	val.MinFileSize("file", 1024)
+	val.MaxFileSize("file", 5)
+	val.FileSizeRange("file", 10, 2*1024*1024)
+	expected := []string{
+		"file must be at least 1 KB.",
+		"file cannot be larger than 5 bytes.",
+		"file must be between 10 bytes and 2 MB.",
+	}
+	if !reflect.DeepEqual(val.Messages(), expected) {
+		t.Errorf("Expected messages %v but got %v.", expected, val.Messages())
+	}
+}
+
+func TestFormatFileSize(t *testing.T) {
+	table := []struct {
+		size     int64
+		expected string
+	}{
+		{0, "0 bytes"},
+		{1, "1 byte"},
+		{1023, "1023 bytes"},
+		{1536, "1.5 KB"},
+		{500 * 1024 * 1024, "500 MB"},
+		{3 * 1024 * 1024 * 1024, "3 GB"},
+	}
+	for _, test := range table {
+		if got := formatFileSize(test.size); got != test.expected {
+			t.Errorf("formatFileSize(%d) was incorrect. Expected %s, but got %s.", test.size, test.expected, got)
+		}
+	}
+}
+
 func ExampleValidator() {
 	// Construct a request object for example purposes only.
 	// Typically you would be using this inside a http.HandlerFunc,